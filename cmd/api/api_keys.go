@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/auth"
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+const apiKeyPrefix = "gsk_"
+
+type CreateAPIKeyPayload struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,required"`
+}
+
+type APIKeyWithSecret struct {
+	*store.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKeyHandler godoc
+//
+//	@Summary		Create an API key
+//	@Description	Creates a scoped API key. The key is only returned in this response
+//	@Tags			api-keys
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateAPIKeyPayload	true	"API key payload"
+//	@Success		201		{object}	APIKeyWithSecret
+//	@Failure		400		{object}	map[string]string
+//	@Failure		401		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/api-keys [post]
+func (app *application) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateAPIKeyPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(&payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	for _, scope := range payload.Scopes {
+		if !auth.IsValidScope(scope) {
+			app.badRequestError(w, r, fmt.Errorf("unknown scope %q", scope))
+			return
+		}
+	}
+
+	plainKey, err := generateAPIKey()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+
+	apiKey := &store.APIKey{
+		UserId: user.ID,
+		Name:   payload.Name,
+		Prefix: plainKey[:len(apiKeyPrefix)+8],
+		Scopes: payload.Scopes,
+	}
+
+	if err := app.store.APIKeys.Create(r.Context(), apiKey, hashToken(plainKey)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, APIKeyWithSecret{apiKey, plainKey}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetAPIKeysHandler godoc
+//
+//	@Summary		List API keys
+//	@Description	Lists the active API keys of the authenticated user without their secrets
+//	@Tags			api-keys
+//	@Produce		json
+//	@Success		200	{array}		store.APIKey
+//	@Failure		401	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/api-keys [get]
+func (app *application) getAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	user := getAuthUserFromCtx(r)
+
+	apiKeys, err := app.store.APIKeys.GetByUser(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, apiKeys); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RevokeAPIKeyHandler godoc
+//
+//	@Summary		Revoke an API key
+//	@Tags			api-keys
+//	@Produce		json
+//	@Param			keyId	path	int	true	"API key ID"
+//	@Success		204
+//	@Failure		400	{object}	map[string]string
+//	@Failure		401	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/api-keys/{keyId} [delete]
+func (app *application) revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	keyId, err := strconv.Atoi(chi.URLParam(r, "keyId"))
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+
+	if err := app.store.APIKeys.Revoke(r.Context(), user.ID, keyId); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func generateAPIKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return apiKeyPrefix + hex.EncodeToString(buf), nil
+}
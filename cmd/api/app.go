@@ -6,6 +6,9 @@ import (
 	"time"
 
 	"github.com/Dinuka-Dilshan/go-web-dev/docs"
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/auth"
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/mailer"
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/ratelimiter"
 	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -16,22 +19,167 @@ import (
 const version = "0.0.2"
 
 type application struct {
-	config config
-	store  store.Storage
-	logger *zap.SugaredLogger
+	config        config
+	store         store.Storage
+	logger        *zap.SugaredLogger
+	authenticator auth.Authenticator
+	mailer        mailer.Mailer
+	oidc          *auth.OIDCProvider
+
+	passwordPolicy    auth.PasswordPolicy
+	breachedPasswords *auth.BreachedPasswords
+	urlSigner         *auth.URLSigner
+
+	// exportQueue feeds pending exports to the workers started by
+	// startBackgroundJobs. It is nil, and every export is turned away, until
+	// they run.
+	exportQueue chan *store.DataExport
+
+	activationLimiter ratelimiter.Limiter
+	magicLinkLimiter  ratelimiter.Limiter
 }
 
 type config struct {
 	address  string
 	dbConfig dbConfig
 	apiUrl   string
-	mail     mailConfig
+	// apiBaseURL is the scheme and host that links back to the API, such as
+	// signed download links, start with.
+	apiBaseURL string
+	// frontendURL is where links in emails point. The pages there ask the
+	// user to confirm before calling the API, so mail clients that prefetch
+	// links cannot use up the tokens in them.
+	frontendURL     string
+	mail            mailConfig
+	auth            authConfig
+	invitation      invitationConfig
+	emailChange     emailChangeConfig
+	accountDeletion accountDeletionConfig
+	export          exportConfig
+	comments        commentConfig
 }
 
 type mailConfig struct {
+	exp       time.Duration
+	fromEmail string
+	smtp      smtpConfig
+	dir       string
+}
+
+type invitationConfig struct {
+	sweepInterval      time.Duration
+	purgeInactiveAfter time.Duration
+	resendLimit        int
+	resendWindow       time.Duration
+}
+
+type emailChangeConfig struct {
+	exp time.Duration
+}
+
+type accountDeletionConfig struct {
+	grace         time.Duration
+	sweepInterval time.Duration
+}
+
+type exportConfig struct {
+	dir           string
+	archiveExp    time.Duration
+	linkExp       time.Duration
+	buildTimeout  time.Duration
+	sweepInterval time.Duration
+	// linkSecret signs download links. It is kept apart from the token
+	// secret so a leaked link key cannot be used to mint access tokens.
+	linkSecret string
+	workers    int
+	queueSize  int
+}
+
+type commentConfig struct {
+	// maxDepth is the deepest a reply can be nested. Top level comments have
+	// depth 0.
+	maxDepth int
+	// pageSize is the number of top level comments listed when no limit is
+	// given, and embedded in post details.
+	pageSize int
+	// repliesPerComment and replyDepth bound the replies listed under each
+	// comment. The rest are paged from the replies endpoint.
+	repliesPerComment int
+	replyDepth        int
+}
+
+type smtpConfig struct {
+	host     string
+	port     int
+	username string
+	password string
+}
+
+type authConfig struct {
+	token         tokenConfig
+	refresh       refreshConfig
+	passwordReset passwordResetConfig
+	password      passwordConfig
+	twoFactor     twoFactorConfig
+	lockout       lockoutConfig
+	oidc          oidcConfig
+	magicLink     magicLinkConfig
+	pruneInterval time.Duration
+}
+
+type tokenConfig struct {
+	secret string
+	exp    time.Duration
+	iss    string
+	aud    string
+}
+
+type refreshConfig struct {
+	exp time.Duration
+}
+
+type passwordResetConfig struct {
 	exp time.Duration
 }
 
+type magicLinkConfig struct {
+	exp    time.Duration
+	limit  int
+	window time.Duration
+}
+
+type passwordConfig struct {
+	minLength      int
+	requireUpper   bool
+	requireLower   bool
+	requireDigit   bool
+	requireSymbol  bool
+	rejectIdentity bool
+	breachedDir    string
+}
+
+type lockoutConfig struct {
+	threshold   int
+	ipThreshold int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	duration    time.Duration
+	window      time.Duration
+}
+
+type twoFactorConfig struct {
+	issuer       string
+	challengeExp time.Duration
+}
+
+type oidcConfig struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	stateExp     time.Duration
+}
+
 type dbConfig struct {
 	address            string
 	maxOpenConnections int32
@@ -51,33 +199,112 @@ func (app *application) mount() http.Handler {
 		))
 
 		r.Route("/post", func(r chi.Router) {
+			r.Use(app.authenticateByMethod(auth.ScopePostsRead, auth.ScopePostsWrite))
+
 			r.Post("/", app.createPostHandler)
 			r.Route("/{postId}", func(r chi.Router) {
 				r.Use(app.postMiddleware)
 
-				r.Delete("/", app.deletePostHandler)
+				r.Delete("/", app.checkPostPolicy(auth.CanDeletePost, app.deletePostHandler))
 				r.Get("/", app.getPostHandler)
-				r.Patch("/", app.updatePostHandler)
+				r.Patch("/", app.checkPostPolicy(auth.CanUpdatePost, app.updatePostHandler))
+
+				r.Route("/comments", func(r chi.Router) {
+					r.Post("/", app.createCommentHandler)
+					r.Get("/", app.getCommentsHandler)
+
+					r.Route("/{commentId}", func(r chi.Router) {
+						r.Use(app.commentMiddleware)
+
+						r.Get("/replies", app.getRepliesHandler)
+						r.Patch("/", app.checkCommentPolicy(auth.CanUpdateComment, app.updateCommentHandler))
+						r.Delete("/", app.checkCommentPolicy(auth.CanDeleteComment, app.deleteCommentHandler))
+					})
+				})
 			})
 
 		})
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
+			r.Post("/activate/resend", app.resendActivationHandler)
+
+			r.Put("/email/confirm/{token}", app.confirmEmailChangeHandler)
+
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.authTokenMiddleware)
+
+				r.Patch("/", app.updateMeHandler)
+				r.Delete("/", app.deleteMeHandler)
+				r.Post("/email", app.requestEmailChangeHandler)
+				r.Post("/export", app.createExportHandler)
+				r.Get("/export/{exportId}", app.getExportHandler)
+
+				r.Route("/follow-requests", func(r chi.Router) {
+					r.Get("/", app.getFollowRequestsHandler)
+					r.Put("/{followerId}/approve", app.approveFollowRequestHandler)
+					r.Put("/{followerId}/reject", app.rejectFollowRequestHandler)
+				})
+
+				r.Route("/api-keys", func(r chi.Router) {
+					r.Post("/", app.createAPIKeyHandler)
+					r.Get("/", app.getAPIKeysHandler)
+					r.Delete("/{keyId}", app.revokeAPIKeyHandler)
+				})
+			})
 
 			r.Route("/{userId}", func(r chi.Router) {
+				r.Use(app.authenticateByMethod(auth.ScopeUsersRead, auth.ScopeFollowsWrite))
 				r.Use(app.userContextMiddleWare)
 				r.Get("/", app.getUserHandler)
 				r.Put("/follow", app.followUserHandler)
 				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Get("/followers", app.getFollowersHandler)
+				r.Get("/following", app.getFollowingHandler)
+				r.Get("/relationship", app.getRelationshipHandler)
+				r.Put("/block", app.blockUserHandler)
+				r.Delete("/block", app.unblockUserHandler)
+				r.Put("/mute", app.muteUserHandler)
+				r.Delete("/mute", app.unmuteUserHandler)
 			})
 
-			r.Group(func(r chi.Router) {
-				r.Get("/feed", app.getUserFeedHandler)
+			r.With(app.authenticate(auth.ScopeFeedRead)).Get("/feed", app.getUserFeedHandler)
+		})
+
+		r.Get("/exports/{exportId}/download", app.downloadExportHandler)
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.authTokenMiddleware)
+			r.Use(app.requireRole(store.RoleAdmin))
+
+			r.Route("/users/{userId}", func(r chi.Router) {
+				r.Use(app.userContextMiddleWare)
+				r.Post("/unlock", app.unlockUserHandler)
 			})
 		})
 
 		r.Route("/auth", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
+			r.Post("/token", app.createTokenHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.Post("/password/forgot", app.forgotPasswordHandler)
+			r.Post("/password/reset", app.resetPasswordHandler)
+			r.Post("/2fa/verify", app.verifyTwoFactorHandler)
+			r.Post("/magic-link", app.requestMagicLinkHandler)
+			r.Post("/magic-link/{token}", app.redeemMagicLinkHandler)
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.authTokenMiddleware)
+
+				r.Post("/logout", app.logoutHandler)
+				r.Post("/logout-all", app.logoutAllHandler)
+				r.Post("/2fa/enroll", app.enrollTwoFactorHandler)
+				r.Post("/2fa/confirm", app.confirmTwoFactorHandler)
+			})
+
+			if app.oidc != nil {
+				r.Get("/oidc/login", app.oidcLoginHandler)
+				r.Get("/oidc/callback", app.oidcCallbackHandler)
+			}
 		})
 	})
 
@@ -1,23 +1,30 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+var (
+	errInvalidCredentials = errors.New("invalid credentials")
+	errInactiveUser       = errors.New("user is not activated")
+)
+
 type RegisterUserPayload struct {
 	Username string `json:"username" validate:"required,max=100"`
 	Email    string `json:"email" validate:"required,email,max=255"`
-	Password string `json:"password" validate:"required,min=3,max=72"`
-}
-
-type UserWithToken struct {
-	store.User
-	Token string `json:"token"`
+	Password string `json:"password" validate:"required,max=72"`
+	Locale   string `json:"locale" validate:"omitempty,bcp47_language_tag"`
 }
 
 func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -28,14 +35,35 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if err := getValidator().Struct(&payload); err != nil {
-		app.badRequestError(w, r, err)
+	fields := make(map[string][]string)
+	if err := getFieldValidator().Struct(&payload); err != nil {
+		invalid, ok := validationFields(err)
+		if !ok {
+			app.badRequestError(w, r, err)
+			return
+		}
+		fields = invalid
+	}
+
+	violations, err := app.checkPassword(payload.Password, payload.Username, payload.Email)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if len(violations) > 0 {
+		fields["password"] = append(fields["password"], violations...)
+	}
+
+	if len(fields) > 0 {
+		app.failedValidationError(w, r, fields)
 		return
 	}
 
 	user := store.User{
 		UserName: payload.Username,
 		Email:    payload.Email,
+		Locale:   payload.Locale,
 	}
 
 	if err := user.Password.Set(payload.Password); err != nil {
@@ -43,9 +71,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	plainToken := uuid.New().String()
-	hash := sha256.Sum256([]byte(plainToken))
-	hashToken := hex.EncodeToString(hash[:])
+	plainToken, hashToken := generateToken()
 
 	if err := app.store.Users.CreateAndInvite(r.Context(), &user, hashToken, app.config.mail.exp); err != nil {
 		switch err {
@@ -62,8 +88,337 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 
 	}
 
-	app.jsonResponse(w, http.StatusOK, UserWithToken{
-		User:  user,
-		Token: plainToken,
-	})
+	if err := app.sendActivationEmail(&user, plainToken); err != nil {
+		app.logger.Errorw("cannot send activation email", "user_id", user.ID, "error", err.Error())
+
+		if err := app.store.Users.Delete(r.Context(), user.ID); err != nil {
+			app.logger.Errorw("cannot roll back user after failed activation email", "user_id", user.ID, "error", err.Error())
+		}
+
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type CreateUserTokenPayload struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,min=3,max=72"`
+}
+
+// CreateTokenHandler godoc
+//
+//	@Summary		Create an access token
+//	@Description	Exchanges user credentials for a signed JWT access token. Users with two-factor enabled get a challenge instead
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateUserTokenPayload	true	"User credentials"
+//	@Success		201		{object}	TokenResponse
+//	@Success		202		{object}	TwoFactorChallenge
+//	@Failure		400		{object}	map[string]string
+//	@Failure		401		{object}	map[string]string
+//	@Failure		429		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Router			/auth/token [post]
+func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateUserTokenPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(&payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	retryAfter, err := app.loginRetryAfter(r, payload.Email)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if retryAfter > 0 {
+		app.rateLimitExceededError(w, r, retryAfter)
+		return
+	}
+
+	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	if err != nil && !errors.Is(err, store.ErrorNotFound) {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if user == nil || user.Password.Compare(payload.Password) != nil {
+		if err := app.recordLoginFailure(r, payload.Email); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		app.unauthorizedError(w, r, errInvalidCredentials)
+		return
+	}
+
+	// With two-factor enabled the password alone does not log the user in, so
+	// failures are only cleared once the challenge has been completed.
+	// Otherwise a known password would reset the count for every wrong code.
+	if !user.TwoFactorEnabled {
+		if err := app.resetLoginFailures(r, payload.Email); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if !user.IsActive {
+		app.unauthorizedError(w, r, errInactiveUser)
+		return
+	}
+
+	app.completeLogin(w, r, user)
+}
+
+// completeLogin issues a token pair for an authenticated user, or a challenge
+// when the user still has to pass two-factor authentication.
+func (app *application) completeLogin(w http.ResponseWriter, r *http.Request, user *store.User) {
+	if user.TwoFactorEnabled {
+		challenge, err := app.createTwoFactorChallenge(r, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if err := app.jsonResponse(w, http.StatusAccepted, challenge); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	tokens, err := app.issueTokens(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// RefreshTokenHandler godoc
+//
+//	@Summary		Refresh an access token
+//	@Description	Rotates a refresh token and returns a new token pair. Reusing a rotated token revokes its whole family
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		201		{object}	TokenResponse
+//	@Failure		400		{object}	map[string]string
+//	@Failure		401		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Router			/auth/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(&payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	refreshToken, refreshHash := generateToken()
+
+	userId, err := app.store.RefreshTokens.Rotate(
+		r.Context(),
+		hashToken(payload.RefreshToken),
+		refreshHash,
+		app.config.auth.refresh.exp,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound), errors.Is(err, store.ErrRefreshTokenReused):
+			app.unauthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	accessToken, err := app.generateAccessToken(userId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens := TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type LogoutPayload struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// LogoutHandler godoc
+//
+//	@Summary		Log out the current session
+//	@Description	Revokes the presented access token and, when given, the family of the refresh token. Refresh tokens of other users are ignored
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body	LogoutPayload	false	"Refresh token of the session"
+//	@Success		204
+//	@Failure		400	{object}	map[string]string
+//	@Failure		401	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/auth/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	var payload LogoutPayload
+
+	if err := readJson(w, r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+	token := getAuthTokenFromCtx(r)
+
+	expiry, err := token.Claims.GetExpirationTime()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Revocations.Revoke(r.Context(), user.ID, getTokenId(token), expiry.Time); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if payload.RefreshToken != "" {
+		if err := app.store.RefreshTokens.Revoke(r.Context(), user.ID, hashToken(payload.RefreshToken)); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// LogoutAllHandler godoc
+//
+//	@Summary		Log out every session
+//	@Description	Revokes all access tokens, refresh tokens and API keys issued to the authenticated user
+//	@Tags			auth
+//	@Produce		json
+//	@Success		204
+//	@Failure		401	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/auth/logout-all [post]
+func (app *application) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	user := getAuthUserFromCtx(r)
+
+	if err := app.revokeSessions(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// revokeSessions signs the user out everywhere: access tokens, refresh tokens
+// and API keys all stop working.
+func (app *application) revokeSessions(ctx context.Context, userId int) error {
+	if err := app.store.Revocations.RevokeAll(ctx, userId, app.config.auth.token.exp); err != nil {
+		return err
+	}
+
+	if err := app.store.RefreshTokens.RevokeAllForUser(ctx, userId); err != nil {
+		return err
+	}
+
+	return app.store.APIKeys.RevokeAllForUser(ctx, userId)
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// issueTokens signs an access token and starts a new refresh token family for the user.
+// Signing in this way cancels a pending account deletion.
+func (app *application) issueTokens(ctx context.Context, userId int) (*TokenResponse, error) {
+	cancelled, err := app.store.Users.CancelDeletion(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if cancelled {
+		app.logger.Infow("account deletion cancelled by login", "user_id", userId)
+	}
+
+	accessToken, err := app.generateAccessToken(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshHash := generateToken()
+
+	if err := app.store.RefreshTokens.Create(ctx, refreshHash, userId, app.config.auth.refresh.exp); err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (app *application) generateAccessToken(userId int) (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		ID:        uuid.New().String(),
+		Subject:   strconv.Itoa(userId),
+		Issuer:    app.config.auth.token.iss,
+		Audience:  jwt.ClaimStrings{app.config.auth.token.aud},
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(app.config.auth.token.exp)),
+	}
+
+	return app.authenticator.GenerateToken(claims)
+}
+
+// generateToken returns a random plain token and the SHA-256 hash that is persisted in its place.
+func generateToken() (string, string) {
+	plainToken := uuid.New().String()
+	return plainToken, hashToken(plainToken)
+}
+
+func hashToken(plainToken string) string {
+	hash := sha256.Sum256([]byte(plainToken))
+	return hex.EncodeToString(hash[:])
 }
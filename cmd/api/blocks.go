@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var errSelfRelationship = errors.New("cannot block or mute yourself")
+
+// BlockUserHandler godoc
+//
+//	@Summary		Block a user
+//	@Description	Removes the follows and follow requests between the authenticated user and the user, prevents new ones and hides their posts and comments from each other
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	map[string]string
+//	@Failure		401	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelationship(w, r, app.store.Blocks.Block)
+}
+
+// UnblockUserHandler godoc
+//
+//	@Summary		Unblock a user
+//	@Description	Lifts a block. Follows removed by the block are not restored
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	map[string]string
+//	@Failure		401	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/block [delete]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelationship(w, r, app.store.Blocks.Unblock)
+}
+
+// MuteUserHandler godoc
+//
+//	@Summary		Mute a user
+//	@Description	Hides the user's posts from the authenticated user's feed without unfollowing them
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	map[string]string
+//	@Failure		401	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/mute [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelationship(w, r, app.store.Blocks.Mute)
+}
+
+// UnmuteUserHandler godoc
+//
+//	@Summary		Unmute a user
+//	@Description	Shows the user's posts in the authenticated user's feed again
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	map[string]string
+//	@Failure		401	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/mute [delete]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelationship(w, r, app.store.Blocks.Unmute)
+}
+
+// updateRelationship applies update between the authenticated user and the
+// user in the path. Blocking or muting twice, or lifting a block or mute that
+// does not exist, is not an error.
+func (app *application) updateRelationship(
+	w http.ResponseWriter,
+	r *http.Request,
+	update func(ctx context.Context, userId int, otherId int) error,
+) {
+	user := getAuthUserFromCtx(r)
+	other := getUserFromCtx(r)
+
+	if user.ID == other.ID {
+		app.badRequestError(w, r, errSelfRelationship)
+		return
+	}
+
+	if err := update(r.Context(), user.ID, other.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/auth"
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type commentKey string
+
+const commentCtx commentKey = "commentKey"
+
+const (
+	commentViewNested = "nested"
+	commentViewFlat   = "flat"
+)
+
+var (
+	errCommentForbidden = errors.New("user is not allowed to modify this comment")
+	errParentNotFound   = errors.New("parent comment not found on this post")
+	errReplyTooDeep     = errors.New("replies cannot be nested any deeper")
+	errInvalidView      = errors.New("view must be nested or flat")
+)
+
+type CreateCommentPayload struct {
+	Content  string `json:"content" validate:"required,max=1000"`
+	ParentId *int   `json:"parent_id" validate:"omitempty,gte=1"`
+}
+
+type UpdateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+// CreateCommentHandler godoc
+//
+//	@Summary		Comment on a post
+//	@Description	Adds a comment by the authenticated user to the post, or a reply to another comment on it when parent_id is set
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postId	path		int						true	"Post ID"
+//	@Param			payload	body		CreateCommentPayload	true	"Comment payload"
+//	@Success		201		{object}	store.Comment
+//	@Failure		400		{object}	map[string]string
+//	@Failure		401		{object}	map[string]string
+//	@Failure		404		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/post/{postId}/comments [post]
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateCommentPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	if payload.ParentId != nil {
+		if err := app.checkReplyParent(r, post, *payload.ParentId); err != nil {
+			switch {
+			case errors.Is(err, errParentNotFound):
+				app.notFoundError(w, r, err)
+			case errors.Is(err, errReplyTooDeep):
+				app.badRequestError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	comment := &store.Comment{
+		PostId:   post.ID,
+		ParentId: payload.ParentId,
+		UserId:   user.ID,
+		UserName: user.UserName,
+		Content:  payload.Content,
+	}
+
+	if err := app.store.Comments.Create(r.Context(), comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundError(w, r, errParentNotFound)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetCommentsHandler godoc
+//
+//	@Summary		List the comments on a post
+//	@Description	Returns the top level comments on the post, oldest first and paginated with a cursor, with their replies. Replies are nested under their parent, or listed after it with their depth and path in the flat view
+//	@Tags			comments
+//	@Produce		json
+//	@Param			postId	path		int		true	"Post ID"
+//	@Param			limit	query		int		false	"Limit"									default(20)
+//	@Param			cursor	query		string	false	"Cursor returned as next_cursor by the previous page"
+//	@Param			view	query		string	false	"nested or flat"						default(nested)
+//	@Success		200		{object}	store.Page[store.Comment]
+//	@Failure		400		{object}	map[string]string
+//	@Failure		401		{object}	map[string]string
+//	@Failure		404		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/post/{postId}/comments [get]
+func (app *application) getCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	query := store.CursorQuery{Limit: app.config.comments.pageSize}
+
+	if err := query.Parse(r); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(query); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	view, err := parseCommentView(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	page, err := app.getCommentPage(r, getPostFromCtx(r), query, view)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// parseCommentView reads how replies should be listed from the view query
+// parameter, nested by default.
+func parseCommentView(r *http.Request) (string, error) {
+	view := r.URL.Query().Get("view")
+
+	switch view {
+	case "":
+		return commentViewNested, nil
+	case commentViewNested, commentViewFlat:
+		return view, nil
+	default:
+		return "", errInvalidView
+	}
+}
+
+// getCommentPage loads a page of the comments on post that the authenticated
+// user can see, with the replies arranged for view.
+func (app *application) getCommentPage(
+	r *http.Request,
+	post *store.Post,
+	query store.CursorQuery,
+	view string,
+) (*store.Page[*store.Comment], error) {
+	page, err := app.store.Comments.GetPage(r.Context(), post.ID, getAuthUserFromCtx(r).ID, query, app.threadLimit())
+	if err != nil {
+		return nil, err
+	}
+
+	if view == commentViewNested {
+		page.Items = store.NestComments(page.Items)
+	}
+
+	return page, nil
+}
+
+// GetRepliesHandler godoc
+//
+//	@Summary		List the replies to a comment
+//	@Description	Returns the direct replies to a comment, oldest first and paginated with a cursor, with their own replies as in the comment list. Use it with the replies_cursor of a comment, or from the start when a comment has more replies than were listed
+//	@Tags			comments
+//	@Produce		json
+//	@Param			postId		path		int		true	"Post ID"
+//	@Param			commentId	path		int		true	"Comment ID"
+//	@Param			limit		query		int		false	"Limit"									default(20)
+//	@Param			cursor		query		string	false	"Cursor returned as next_cursor by the previous page, or replies_cursor of the comment"
+//	@Param			view		query		string	false	"nested or flat"						default(nested)
+//	@Success		200			{object}	store.Page[store.Comment]
+//	@Failure		400			{object}	map[string]string
+//	@Failure		401			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/post/{postId}/comments/{commentId}/replies [get]
+func (app *application) getRepliesHandler(w http.ResponseWriter, r *http.Request) {
+	query := store.CursorQuery{Limit: app.config.comments.pageSize}
+
+	if err := query.Parse(r); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(query); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	view, err := parseCommentView(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	viewer := getAuthUserFromCtx(r)
+	comment := getCommentFromCtx(r)
+
+	// Replies under a comment hidden by a block are hidden with it.
+	blocked, err := app.store.Blocks.IsBlocked(r.Context(), viewer.ID, comment.UserId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if blocked {
+		app.notFoundError(w, r, store.ErrorNotFound)
+		return
+	}
+
+	page, err := app.store.Comments.GetReplies(r.Context(), comment.ID, viewer.ID, query, app.threadLimit())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if view == commentViewNested {
+		page.Items = store.NestComments(page.Items)
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) threadLimit() store.ThreadLimit {
+	return store.ThreadLimit{
+		PerComment: app.config.comments.repliesPerComment,
+		Depth:      app.config.comments.replyDepth,
+	}
+}
+
+// UpdateCommentHandler godoc
+//
+//	@Summary		Edit a comment
+//	@Description	Replaces the content of a comment. Only its author can edit it
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postId		path		int						true	"Post ID"
+//	@Param			commentId	path		int						true	"Comment ID"
+//	@Param			payload		body		UpdateCommentPayload	true	"Comment payload"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	map[string]string
+//	@Failure		401			{object}	map[string]string
+//	@Failure		403			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/post/{postId}/comments/{commentId} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateCommentPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	comment := getCommentFromCtx(r)
+	comment.Content = payload.Content
+
+	if err := app.store.Comments.Update(r.Context(), comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteCommentHandler godoc
+//
+//	@Summary		Delete a comment
+//	@Description	Deletes a comment. Its author and the owner of the post can delete it. A comment with replies is replaced by a [deleted] placeholder so the replies stay visible
+//	@Tags			comments
+//	@Produce		json
+//	@Param			postId		path	int	true	"Post ID"
+//	@Param			commentId	path	int	true	"Comment ID"
+//	@Success		204
+//	@Failure		400	{object}	map[string]string
+//	@Failure		401	{object}	map[string]string
+//	@Failure		403	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/post/{postId}/comments/{commentId} [delete]
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.store.Comments.Delete(r.Context(), getCommentFromCtx(r).ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// commentMiddleware loads the comment in the path. It has to run after
+// postMiddleware, and comments on other posts are not found.
+func (app *application) commentMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commentId, err := strconv.Atoi(chi.URLParam(r, "commentId"))
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+
+		comment, err := app.store.Comments.GetById(r.Context(), commentId)
+		if err == nil && comment.PostId != getPostFromCtx(r).ID {
+			err = store.ErrorNotFound
+		}
+
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrorNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), commentCtx, comment)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// checkReplyParent makes sure a reply to parentId would be on post, under a
+// comment the authenticated user can see, and no deeper than allowed.
+func (app *application) checkReplyParent(r *http.Request, post *store.Post, parentId int) error {
+	parent, err := app.store.Comments.GetById(r.Context(), parentId)
+	if err != nil {
+		if errors.Is(err, store.ErrorNotFound) {
+			return errParentNotFound
+		}
+		return err
+	}
+
+	if parent.PostId != post.ID || parent.Deleted {
+		return errParentNotFound
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(r.Context(), getAuthUserFromCtx(r).ID, parent.UserId)
+	if err != nil {
+		return err
+	}
+
+	if blocked {
+		return errParentNotFound
+	}
+
+	if parent.Depth+1 > app.config.comments.maxDepth {
+		return errReplyTooDeep
+	}
+
+	return nil
+}
+
+func getCommentFromCtx(r *http.Request) *store.Comment {
+	comment, _ := r.Context().Value(commentCtx).(*store.Comment)
+	return comment
+}
+
+// checkCommentPolicy guards changes to the comment in the path. Deleted
+// comments kept as placeholders cannot be changed and are not found.
+func (app *application) checkCommentPolicy(policy auth.CommentPolicy, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		comment := getCommentFromCtx(r)
+
+		if comment.Deleted {
+			app.notFoundError(w, r, store.ErrorNotFound)
+			return
+		}
+
+		if !policy(getAuthUserFromCtx(r), getPostFromCtx(r), comment) {
+			app.forbiddenError(w, r, errCommentForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
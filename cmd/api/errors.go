@@ -1,9 +1,26 @@
 package main
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
 )
 
+// clientConflicts are the conflicts whose message is safe to show to clients.
+// Any other error gets the generic message so store internals do not leak.
+var clientConflicts = []error{
+	errAlreadyFollowing,
+	errFollowRequestPending,
+	errTwoFactorEnabled,
+	errExportPending,
+	store.ErrDuplicateEmail,
+	store.ErrDuplicateUsername,
+}
+
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
 	app.logger.Errorw("internal server error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
 
@@ -15,8 +32,16 @@ func (app *application) internalServerError(w http.ResponseWriter, r *http.Reque
 func (app *application) conflictError(w http.ResponseWriter, r *http.Request, err error) {
 	app.logger.Errorw("conflict error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
 
+	message := "resource already exsists"
+	for _, conflict := range clientConflicts {
+		if errors.Is(err, conflict) {
+			message = conflict.Error()
+			break
+		}
+	}
+
 	writeJson(w, http.StatusConflict, map[string]string{
-		"error": "resource already exsists",
+		"error": message,
 	})
 }
 
@@ -35,3 +60,48 @@ func (app *application) badRequestError(w http.ResponseWriter, r *http.Request,
 		"error": err.Error(),
 	})
 }
+
+// failedValidationError reports problems with individual fields, keyed by
+// their JSON name.
+func (app *application) failedValidationError(w http.ResponseWriter, r *http.Request, fields map[string][]string) {
+	app.logger.Warnw("failed validation error", "method", r.Method, "path", r.URL.Path, "fields", fields)
+
+	writeJson(w, http.StatusUnprocessableEntity, map[string]any{
+		"error":  "validation failed",
+		"fields": fields,
+	})
+}
+
+func (app *application) unauthorizedError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("unauthorized error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJson(w, http.StatusUnauthorized, map[string]string{
+		"error": "unauthorized",
+	})
+}
+
+func (app *application) forbiddenError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("forbidden error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJson(w, http.StatusForbidden, map[string]string{
+		"error": "forbidden",
+	})
+}
+
+func (app *application) serviceUnavailableError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("service unavailable error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJson(w, http.StatusServiceUnavailable, map[string]string{
+		"error": err.Error(),
+	})
+}
+
+func (app *application) rateLimitExceededError(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.logger.Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path)
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+
+	writeJson(w, http.StatusTooManyRequests, map[string]string{
+		"error": "rate limit exceeded, retry in " + retryAfter.Round(time.Second).String(),
+	})
+}
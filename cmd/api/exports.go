@@ -0,0 +1,292 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	errExportPending  = errors.New("an export is already being prepared")
+	errExportNotReady = errors.New("export is not ready")
+	errExportsBusy    = errors.New("too many exports are being prepared, try again later")
+)
+
+type DataExportResponse struct {
+	*store.DataExport
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+type exportManifest struct {
+	UserId      int       `json:"user_id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Sections    []string  `json:"sections"`
+}
+
+// CreateExportHandler godoc
+//
+//	@Summary		Export personal data
+//	@Description	Starts building a ZIP archive with the user's profile, posts, comments, follows and sessions. Poll the export until it is ready to get a download link
+//	@Tags			users
+//	@Produce		json
+//	@Success		202	{object}	DataExportResponse
+//	@Failure		401	{object}	map[string]string
+//	@Failure		409	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Failure		503	{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/export [post]
+func (app *application) createExportHandler(w http.ResponseWriter, r *http.Request) {
+	user := getAuthUserFromCtx(r)
+
+	export := &store.DataExport{UserId: user.ID}
+
+	if err := app.store.Exports.Create(r.Context(), export); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorConflict):
+			app.conflictError(w, r, errExportPending)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// The worker gets its own copy, since it updates the export while this
+	// response is still being written.
+	queued := *export
+
+	select {
+	case app.exportQueue <- &queued:
+	default:
+		// Fail the export straight away so it does not block a retry.
+		if err := app.store.Exports.Complete(r.Context(), export, store.ExportFailed, 0); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		app.serviceUnavailableError(w, r, errExportsBusy)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, DataExportResponse{DataExport: export}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetExportHandler godoc
+//
+//	@Summary		Get a personal data export
+//	@Description	Returns the state of an export. Ready exports include a signed download link that expires after a short while
+//	@Tags			users
+//	@Produce		json
+//	@Param			exportId	path		int	true	"Export ID"
+//	@Success		200			{object}	DataExportResponse
+//	@Failure		400			{object}	map[string]string
+//	@Failure		401			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/export/{exportId} [get]
+func (app *application) getExportHandler(w http.ResponseWriter, r *http.Request) {
+	user := getAuthUserFromCtx(r)
+
+	exportId, err := strconv.Atoi(chi.URLParam(r, "exportId"))
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	export, err := app.store.Exports.GetById(r.Context(), exportId)
+	if err == nil && export.UserId != user.ID {
+		err = store.ErrorNotFound
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	response := DataExportResponse{DataExport: export}
+	if export.Status == store.ExportReady {
+		response.DownloadURL = app.exportDownloadURL(export)
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DownloadExportHandler godoc
+//
+//	@Summary		Download a personal data export
+//	@Description	Serves the ZIP archive of an export. The link is signed and only valid until it expires
+//	@Tags			users
+//	@Produce		application/zip
+//	@Param			exportId	path	int		true	"Export ID"
+//	@Param			expires		query	int		true	"Link expiry as a unix timestamp"
+//	@Param			signature	query	string	true	"Link signature"
+//	@Success		200
+//	@Failure		403	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Router			/exports/{exportId}/download [get]
+func (app *application) downloadExportHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.urlSigner.Verify(r.URL.Path, r.URL.Query()); err != nil {
+		app.forbiddenError(w, r, err)
+		return
+	}
+
+	exportId, err := strconv.Atoi(chi.URLParam(r, "exportId"))
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	export, err := app.store.Exports.GetById(r.Context(), exportId)
+	if err == nil && (export.Status != store.ExportReady || export.Expiry.Before(time.Now())) {
+		err = errExportNotReady
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound), errors.Is(err, errExportNotReady):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="gophersocial-export-%d.zip"`, export.ID))
+	http.ServeFile(w, r, app.exportPath(export.ID))
+}
+
+func (app *application) exportDownloadURL(export *store.DataExport) string {
+	path := fmt.Sprintf("/v1/exports/%d/download", export.ID)
+
+	expiry := time.Now().Add(app.config.export.linkExp)
+	if export.Expiry != nil && export.Expiry.Before(expiry) {
+		expiry = *export.Expiry
+	}
+
+	return fmt.Sprintf("%s%s?%s", app.config.apiBaseURL, path, app.urlSigner.Sign(path, expiry).Encode())
+}
+
+func (app *application) exportPath(exportId int) string {
+	return filepath.Join(app.config.export.dir, fmt.Sprintf("%d.zip", exportId))
+}
+
+// buildExport writes the archive of a pending export and records whether it
+// succeeded. Builds are cut short when ctx is done.
+func (app *application) buildExport(ctx context.Context, export *store.DataExport) {
+	// The sweep may have failed the export while it sat in the queue, in which
+	// case there is nothing left to build.
+	if err := app.store.Exports.Start(ctx, export); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.logger.Warnw("skipped data export that is no longer pending", "export_id", export.ID)
+		default:
+			app.logger.Errorw("cannot start data export", "export_id", export.ID, "error", err.Error())
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, app.config.export.buildTimeout)
+	defer cancel()
+
+	status := store.ExportReady
+	if err := app.writeExportArchive(ctx, export); err != nil {
+		app.logger.Errorw("cannot build data export", "export_id", export.ID, "user_id", export.UserId, "error", err.Error())
+		status = store.ExportFailed
+	}
+
+	// The build may have used up ctx, so the outcome is recorded regardless.
+	if err := app.store.Exports.Complete(context.Background(), export, status, app.config.export.archiveExp); err != nil {
+		app.logger.Errorw("cannot complete data export", "export_id", export.ID, "error", err.Error())
+	}
+}
+
+// writeExportArchive stores a JSON file for every store section, next to a
+// manifest, in a ZIP archive. It is written under a temporary name so
+// downloads never see a partial archive. Users cannot upload files, so there
+// are no attachments to add; avatars are links and come with the profile.
+func (app *application) writeExportArchive(ctx context.Context, export *store.DataExport) (err error) {
+	if err := os.MkdirAll(app.config.export.dir, 0o750); err != nil {
+		return err
+	}
+
+	path := app.exportPath(export.ID)
+	tmpPath := path + ".tmp"
+
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	archive := zip.NewWriter(file)
+
+	manifest := exportManifest{
+		UserId:      export.UserId,
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	for _, section := range app.store.ExportSections() {
+		data, err := section.Exporter.Export(ctx, export.UserId)
+		if err != nil {
+			return fmt.Errorf("cannot export %s: %w", section.Name, err)
+		}
+
+		if err := writeArchiveJson(archive, section.Name+".json", data); err != nil {
+			return err
+		}
+
+		manifest.Sections = append(manifest.Sections, section.Name)
+	}
+
+	if err := writeArchiveJson(archive, "manifest.json", manifest); err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+func writeArchiveJson(archive *zip.Writer, name string, data any) error {
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(data)
+}
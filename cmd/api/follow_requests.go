@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/auth"
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+const followRequested = "requested"
+
+var errFollowRequestPending = errors.New("already following or requested to follow this user")
+
+type FollowRequestStatus struct {
+	Status string `json:"status"`
+}
+
+// requestFollow asks the owner of a private account to approve user as a follower.
+func (app *application) requestFollow(w http.ResponseWriter, r *http.Request, user *store.User, followUser *store.User) {
+	if err := app.store.Followers.RequestFollow(r.Context(), user.ID, followUser.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorConflict):
+			app.conflictError(w, r, errFollowRequestPending)
+		case errors.Is(err, store.ErrBlocked):
+			app.forbiddenError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, FollowRequestStatus{Status: followRequested}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetFollowRequestsHandler godoc
+//
+//	@Summary		List follow requests
+//	@Description	Returns the pending requests to follow the authenticated user, most recent first, paginated with a cursor
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"									default(20)
+//	@Param			cursor	query		string	false	"Cursor returned as next_cursor by the previous page"
+//	@Success		200		{object}	store.Page[store.UserSummary]
+//	@Failure		400		{object}	map[string]string
+//	@Failure		401		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests [get]
+func (app *application) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	query := store.CursorQuery{Limit: 20}
+
+	if err := query.Parse(r); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(query); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	page, err := app.store.Followers.GetFollowRequests(r.Context(), getAuthUserFromCtx(r).ID, query)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ApproveFollowRequestHandler godoc
+//
+//	@Summary		Approve a follow request
+//	@Description	Lets the requesting user follow the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Param			followerId	path	int	true	"Requesting user ID"
+//	@Success		204
+//	@Failure		400	{object}	map[string]string
+//	@Failure		401	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{followerId}/approve [put]
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, app.store.Followers.ApproveFollowRequest)
+}
+
+// RejectFollowRequestHandler godoc
+//
+//	@Summary		Reject a follow request
+//	@Description	Discards a pending request to follow the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Param			followerId	path	int	true	"Requesting user ID"
+//	@Success		204
+//	@Failure		400	{object}	map[string]string
+//	@Failure		401	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{followerId}/reject [put]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, app.store.Followers.RejectFollowRequest)
+}
+
+func (app *application) answerFollowRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+	answer func(ctx context.Context, userId int, followerId int) error,
+) {
+	followerId, err := strconv.Atoi(chi.URLParam(r, "followerId"))
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := answer(r.Context(), getAuthUserFromCtx(r).ID, followerId); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// canViewPosts reports whether the authenticated user may see posts by author.
+func (app *application) canViewPosts(r *http.Request, author *store.User) (bool, error) {
+	viewer := getAuthUserFromCtx(r)
+
+	if viewer.ID == author.ID {
+		return true, nil
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(r.Context(), viewer.ID, author.ID)
+	if err != nil || blocked {
+		return false, err
+	}
+
+	if !author.IsPrivate {
+		return true, nil
+	}
+
+	following, err := app.store.Followers.IsFollowing(r.Context(), viewer.ID, author.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return auth.CanViewPosts(viewer, author, following), nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
@@ -16,11 +17,12 @@ import (
 //	@Param			limit	query		int		false	"Limit"						default(10)
 //	@Param			offset	query		int		false	"Offset"					default(0)
 //	@Param			sort	query		string	false	"Sort order (ASC or DESC)"	default(DESC)
-//	@Param			body	body		object	true	"User ID"
 //	@Success		200		{array}		map[string]interface{}
 //	@Failure		400		{string}	string	"Bad request"
+//	@Failure		401		{object}	map[string]string
 //	@Failure		500		{object}	map[string]string
-//	@Router			/post/feed [post]
+//	@Security		ApiKeyAuth
+//	@Router			/users/feed [get]
 func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Request) {
 
 	var paginatedQuery = store.PaginatedQuery{
@@ -39,28 +41,131 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	type Body struct {
-		UserID int `json:"user_id"`
+	user := getAuthUserFromCtx(r)
+
+	posts, err := app.store.Posts.GetUserFeed(
+		r.Context(),
+		user.ID,
+		paginatedQuery,
+	)
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
 	}
 
-	var body Body
+	app.jsonResponse(w, http.StatusOK, posts)
 
-	if err := readJson(w, r, &body); err != nil {
+}
+
+// GetFollowersHandler godoc
+//
+//	@Summary		List followers
+//	@Description	Returns the users following the user, most recent first, paginated with a cursor
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"									default(20)
+//	@Param			cursor	query		string	false	"Cursor returned as next_cursor by the previous page"
+//	@Success		200		{object}	store.Page[store.UserSummary]
+//	@Failure		400		{object}	map[string]string
+//	@Failure		401		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/followers [get]
+func (app *application) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.store.Followers.GetFollowers)
+}
+
+// GetFollowingHandler godoc
+//
+//	@Summary		List followed users
+//	@Description	Returns the users the user follows, most recent first, paginated with a cursor
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"									default(20)
+//	@Param			cursor	query		string	false	"Cursor returned as next_cursor by the previous page"
+//	@Success		200		{object}	store.Page[store.UserSummary]
+//	@Failure		400		{object}	map[string]string
+//	@Failure		401		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/following [get]
+func (app *application) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.store.Followers.GetFollowing)
+}
+
+func (app *application) listFollows(
+	w http.ResponseWriter,
+	r *http.Request,
+	list func(context.Context, int, store.CursorQuery) (*store.Page[*store.UserSummary], error),
+) {
+	query := store.CursorQuery{Limit: 20}
+
+	if err := query.Parse(r); err != nil {
 		app.badRequestError(w, r, err)
 		return
 	}
 
-	posts, err := app.store.Posts.GetUserFeed(
-		r.Context(),
-		body.UserID,
-		paginatedQuery,
-	)
+	if err := getValidator().Struct(query); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
 
+	page, err := list(r.Context(), getUserFromCtx(r).ID, query)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	app.jsonResponse(w, http.StatusOK, posts)
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
 
+type Relationship struct {
+	UserId     int  `json:"user_id"`
+	Following  bool `json:"following"`
+	FollowedBy bool `json:"followed_by"`
+}
+
+// GetRelationshipHandler godoc
+//
+//	@Summary		Get the relationship with a user
+//	@Description	Reports whether the authenticated user follows the user and whether the user follows them back
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path		int	true	"User ID"
+//	@Success		200		{object}	Relationship
+//	@Failure		400		{object}	map[string]string
+//	@Failure		401		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/relationship [get]
+func (app *application) getRelationshipHandler(w http.ResponseWriter, r *http.Request) {
+	viewer := getAuthUserFromCtx(r)
+	user := getUserFromCtx(r)
+
+	following, err := app.store.Followers.IsFollowing(r.Context(), viewer.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	followedBy, err := app.store.Followers.IsFollowing(r.Context(), user.ID, viewer.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	relationship := Relationship{
+		UserId:     user.ID,
+		Following:  following,
+		FollowedBy: followedBy,
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, relationship); err != nil {
+		app.internalServerError(w, r, err)
+	}
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+)
+
+func (app *application) startBackgroundJobs(ctx context.Context) {
+	app.exportQueue = make(chan *store.DataExport, app.config.export.queueSize)
+	for range app.config.export.workers {
+		go app.runExportWorker(ctx)
+	}
+
+	go app.runPeriodically(ctx, "prune revoked tokens", app.config.auth.pruneInterval, app.pruneRevokedTokens)
+	go app.runPeriodically(ctx, "sweep invitations", app.config.invitation.sweepInterval, app.sweepInvitations)
+	go app.runPeriodically(ctx, "delete accounts", app.config.accountDeletion.sweepInterval, app.deleteScheduledAccounts)
+	go app.runPeriodically(ctx, "sweep exports", app.config.export.sweepInterval, app.sweepExports)
+}
+
+func (app *application) runPeriodically(
+	ctx context.Context,
+	name string,
+	interval time.Duration,
+	job func(context.Context) error,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				app.logger.Errorw("background job failed", "job", name, "error", err.Error())
+			}
+		}
+	}
+}
+
+// runExportWorker builds queued exports one at a time until ctx is done.
+// Exports still queued then stay pending until sweepExports fails them.
+func (app *application) runExportWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case export := <-app.exportQueue:
+			app.buildExport(ctx, export)
+		}
+	}
+}
+
+func (app *application) pruneRevokedTokens(ctx context.Context) error {
+	revocations, err := app.store.Revocations.DeleteExpired(ctx)
+	if err != nil {
+		return err
+	}
+
+	refreshTokens, err := app.store.RefreshTokens.DeleteExpired(ctx)
+	if err != nil {
+		return err
+	}
+
+	challenges, err := app.store.TwoFactor.DeleteExpiredChallenges(ctx)
+	if err != nil {
+		return err
+	}
+
+	loginAttempts, err := app.store.LoginAttempts.DeleteStale(ctx, app.config.auth.lockout.window)
+	if err != nil {
+		return err
+	}
+
+	loginStates, err := app.store.Identities.DeleteExpiredLoginStates(ctx)
+	if err != nil {
+		return err
+	}
+
+	magicLinks, err := app.store.Users.DeleteExpiredMagicLinks(ctx)
+	if err != nil {
+		return err
+	}
+
+	emailChanges, err := app.store.Users.DeleteExpiredEmailChanges(ctx)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Infow(
+		"pruned expired tokens",
+		"revocations", revocations,
+		"refresh_tokens", refreshTokens,
+		"two_factor_challenges", challenges,
+		"login_attempts", loginAttempts,
+		"oidc_login_states", loginStates,
+		"magic_links", magicLinks,
+		"email_changes", emailChanges,
+	)
+
+	return nil
+}
+
+// sweepInvitations drops expired invitations and, when purgeInactiveAfter is
+// set, accounts that were never activated.
+func (app *application) sweepInvitations(ctx context.Context) error {
+	invitations, err := app.store.Users.DeleteExpiredInvitations(ctx)
+	if err != nil {
+		return err
+	}
+
+	var users int64
+	if app.config.invitation.purgeInactiveAfter > 0 {
+		users, err = app.store.Users.PurgeInactive(ctx, app.config.invitation.purgeInactiveAfter)
+		if err != nil {
+			return err
+		}
+	}
+
+	app.logger.Infow("swept invitations", "invitations", invitations, "users", users)
+
+	return nil
+}
+
+// deleteScheduledAccounts removes the accounts whose deletion grace period has
+// ended, along with their posts.
+func (app *application) deleteScheduledAccounts(ctx context.Context) error {
+	users, err := app.store.Users.DeleteScheduled(ctx)
+	if users > 0 {
+		app.logger.Infow("deleted scheduled accounts", "users", users)
+	}
+
+	return err
+}
+
+// sweepExports fails exports that never finished and deletes expired archives.
+func (app *application) sweepExports(ctx context.Context) error {
+	config := app.config.export
+
+	// Queued exports wait for the ones ahead of them, so they get as long as
+	// a full queue takes to drain before they count as lost.
+	queueTimeout := config.buildTimeout * time.Duration(config.queueSize/max(config.workers, 1)+2)
+
+	failed, err := app.store.Exports.FailStale(ctx, config.buildTimeout*2, queueTimeout)
+	if err != nil {
+		return err
+	}
+
+	exportIds, err := app.store.Exports.DeleteExpired(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, exportId := range exportIds {
+		if err := os.Remove(app.exportPath(exportId)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			app.logger.Errorw("cannot delete export archive", "export_id", exportId, "error", err.Error())
+		}
+	}
+
+	// Archives of deleted accounts lose their rows straight away, so anything
+	// older than the retention period is removed as well.
+	orphans, err := removeFilesOlderThan(app.config.export.dir, app.config.export.archiveExp)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Infow("swept data exports", "failed", failed, "expired", len(exportIds), "orphans", orphans)
+
+	return nil
+}
+
+func removeFilesOlderThan(dir string, age time.Duration) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-age)
+	removed := 0
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err == nil {
+			removed++
+		}
+	}
+
+	return removed, nil
+}
@@ -2,7 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"reflect"
+	"strings"
 	"sync"
 
 	"github.com/go-playground/validator/v10"
@@ -11,6 +15,9 @@ import (
 var (
 	validatorInstance *validator.Validate
 	once              sync.Once
+
+	fieldValidatorInstance *validator.Validate
+	fieldOnce              sync.Once
 )
 
 func getValidator() *validator.Validate {
@@ -21,6 +28,44 @@ func getValidator() *validator.Validate {
 	return validatorInstance
 }
 
+// getFieldValidator reports fields by their JSON name. It is kept apart from
+// getValidator so the messages every other handler returns stay the same.
+func getFieldValidator() *validator.Validate {
+	fieldOnce.Do(func() {
+		fieldValidatorInstance = validator.New(validator.WithRequiredStructEnabled())
+		fieldValidatorInstance.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	})
+
+	return fieldValidatorInstance
+}
+
+// validationFields groups errors from getFieldValidator by field. ok is false
+// when err is not a validation failure.
+func validationFields(err error) (fields map[string][]string, ok bool) {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return nil, false
+	}
+
+	fields = make(map[string][]string)
+	for _, fieldError := range validationErrors {
+		message := fmt.Sprintf("failed on the %q rule", fieldError.Tag())
+		if fieldError.Param() != "" {
+			message = fmt.Sprintf("failed on the %q rule with %q", fieldError.Tag(), fieldError.Param())
+		}
+
+		fields[fieldError.Field()] = append(fields[fieldError.Field()], message)
+	}
+
+	return fields, true
+}
+
 func writeJson(w http.ResponseWriter, status int, data any) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
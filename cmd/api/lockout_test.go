@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutDelay(t *testing.T) {
+	lockout := lockoutConfig{
+		threshold: 5,
+		baseDelay: time.Second,
+		maxDelay:  5 * time.Second,
+		duration:  15 * time.Minute,
+	}
+
+	tests := []struct {
+		name      string
+		lockout   lockoutConfig
+		failures  int
+		threshold int
+		want      time.Duration
+	}{
+		{"no failures yet", lockout, 0, 5, time.Second},
+		{"first failure", lockout, 1, 5, time.Second},
+		{"second failure doubles", lockout, 2, 5, 2 * time.Second},
+		{"third failure doubles again", lockout, 3, 5, 4 * time.Second},
+		{"capped at max delay", lockout, 4, 5, 5 * time.Second},
+		{"threshold locks out", lockout, 5, 5, 15 * time.Minute},
+		{"past threshold stays locked out", lockout, 6, 5, 15 * time.Minute},
+		{"ip threshold is separate", lockout, 6, 20, 5 * time.Second},
+		{"shift overflow is capped", lockout, 70, 100, 5 * time.Second},
+		{"threshold of one", lockout, 1, 1, 15 * time.Minute},
+		{
+			"max delay above duration is still used before threshold",
+			lockoutConfig{baseDelay: time.Hour, maxDelay: 2 * time.Hour, duration: time.Minute},
+			2, 5, 2 * time.Hour,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.lockout.delay(test.failures, test.threshold); got != test.want {
+				t.Errorf("delay(%d, %d) = %v, want %v", test.failures, test.threshold, got, test.want)
+			}
+		})
+	}
+}
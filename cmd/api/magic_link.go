@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+var errInvalidMagicLink = errors.New("magic link is invalid, expired or already used")
+
+type MagicLinkPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// RequestMagicLinkHandler godoc
+//
+//	@Summary		Request a sign-in link
+//	@Description	Emails a single-use, short-lived sign-in link to an active account. The link opens a frontend page that calls POST /auth/magic-link/{token} once the user confirms. Always responds 202 so emails cannot be enumerated
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body	MagicLinkPayload	true	"Account email"
+//	@Success		202
+//	@Failure		400	{object}	map[string]string
+//	@Failure		429	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Router			/auth/magic-link [post]
+func (app *application) requestMagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MagicLinkPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(&payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if allowed, retryAfter := app.magicLinkLimiter.Allow(strings.ToLower(payload.Email)); !allowed {
+		app.rateLimitExceededError(w, r, retryAfter)
+		return
+	}
+
+	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	if err != nil && !errors.Is(err, store.ErrorNotFound) {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if user != nil && user.IsActive {
+		plainToken, hashToken := generateToken()
+
+		if err := app.store.Users.CreateMagicLink(r.Context(), user.ID, hashToken, app.config.auth.magicLink.exp); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if err := app.sendMagicLinkEmail(user, plainToken); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RedeemMagicLinkHandler godoc
+//
+//	@Summary		Sign in with a magic link
+//	@Description	Exchanges a sign-in link token for an access and refresh token. Returns a two-factor challenge instead when the account has it enabled
+//	@Tags			auth
+//	@Produce		json
+//	@Param			token	path		string	true	"Magic link token"
+//	@Success		201		{object}	TokenResponse
+//	@Success		202		{object}	TwoFactorChallenge
+//	@Failure		401		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Router			/auth/magic-link/{token} [post]
+func (app *application) redeemMagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := app.store.Users.RedeemMagicLink(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.unauthorizedError(w, r, errInvalidMagicLink)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if !user.IsActive {
+		app.unauthorizedError(w, r, errInactiveUser)
+		return
+	}
+
+	app.completeLogin(w, r, user)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/mailer"
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+)
+
+const mailTimeFormat = "2006-01-02 15:04 MST"
+
+// frontendLink returns the link to the frontend page that completes an action
+// with token, such as /activate/{token} which calls PUT /v1/users/activate/{token}.
+func (app *application) frontendLink(page string, token string) string {
+	return strings.TrimRight(app.config.frontendURL, "/") + "/" + page + "/" + url.PathEscape(token)
+}
+
+func (app *application) sendTemplatedEmail(user *store.User, name string, data any) error {
+	message, err := mailer.Render(name, user.Locale, data)
+	if err != nil {
+		return err
+	}
+
+	message.To = user.Email
+
+	return app.mailer.Send(message)
+}
+
+func (app *application) sendActivationEmail(user *store.User, token string) error {
+	return app.sendTemplatedEmail(user, mailer.ActivationTemplate, mailer.ActivationData{
+		UserName:      user.UserName,
+		ActivationURL: app.frontendLink("activate", token),
+		Expiry:        time.Now().Add(app.config.mail.exp).Format(mailTimeFormat),
+	})
+}
+
+func (app *application) sendPasswordResetEmail(user *store.User, token string) error {
+	return app.sendTemplatedEmail(user, mailer.PasswordResetTemplate, mailer.PasswordResetData{
+		UserName: user.UserName,
+		ResetURL: app.frontendLink("reset-password", token),
+		Expiry:   time.Now().Add(app.config.auth.passwordReset.exp).Format(mailTimeFormat),
+	})
+}
+
+func (app *application) sendNewFollowerEmail(user *store.User, follower *store.User) error {
+	return app.sendTemplatedEmail(user, mailer.NewFollowerTemplate, mailer.NewFollowerData{
+		UserName:     user.UserName,
+		FollowerName: follower.UserName,
+	})
+}
+
+func (app *application) sendMagicLinkEmail(user *store.User, token string) error {
+	return app.sendTemplatedEmail(user, mailer.MagicLinkTemplate, mailer.MagicLinkData{
+		UserName: user.UserName,
+		LoginURL: app.frontendLink("magic-link", token),
+		Expiry:   time.Now().Add(app.config.auth.magicLink.exp).Format(mailTimeFormat),
+	})
+}
+
+// sendEmailChangeEmail asks the owner of the new address to confirm it, so it
+// goes to email rather than the address on the account.
+func (app *application) sendEmailChangeEmail(user *store.User, email string, token string) error {
+	recipient := *user
+	recipient.Email = email
+
+	return app.sendTemplatedEmail(&recipient, mailer.EmailChangeTemplate, mailer.EmailChangeData{
+		UserName:   user.UserName,
+		Email:      email,
+		ConfirmURL: app.frontendLink("confirm-email", token),
+		Expiry:     time.Now().Add(app.config.emailChange.exp).Format(mailTimeFormat),
+	})
+}
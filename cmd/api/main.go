@@ -4,9 +4,13 @@ import (
 	"context"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/auth"
 	"github.com/Dinuka-Dilshan/go-web-dev/internal/db"
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/mailer"
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/ratelimiter"
 	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
@@ -22,6 +26,11 @@ import (
 
 // @host		localhost:3000
 // @BasePath	/v1/
+
+// @securityDefinitions.apikey	ApiKeyAuth
+// @in							header
+// @name						Authorization
+// @description				"Bearer <access token>" issued by /auth/token or "ApiKey <key>" for scoped API keys
 func main() {
 	zap, err := zap.NewProduction()
 	logger := zap.Sugar()
@@ -45,6 +54,34 @@ func main() {
 		logger.Fatal("cannot find database url")
 	}
 
+	tokenSecret, ok := os.LookupEnv("AUTH_TOKEN_SECRET")
+	if !ok {
+		logger.Fatal("cannot find auth token secret")
+	}
+
+	linkSecret, ok := os.LookupEnv("URL_SIGNING_SECRET")
+	if !ok {
+		logger.Fatal("cannot find url signing secret")
+	}
+
+	apiBaseURL, ok := os.LookupEnv("API_BASE_URL")
+	if !ok {
+		apiBaseURL = "http://localhost:3000"
+	}
+
+	frontendURL, ok := os.LookupEnv("FRONTEND_URL")
+	if !ok {
+		frontendURL = "http://localhost:5173"
+	}
+
+	smtpPort := 587
+	if port, ok := os.LookupEnv("SMTP_PORT"); ok {
+		smtpPort, err = strconv.Atoi(port)
+		if err != nil {
+			logger.Fatal("invalid smtp port")
+		}
+	}
+
 	config := &config{
 		address: port,
 		dbConfig: dbConfig{
@@ -52,9 +89,96 @@ func main() {
 			maxOpenConnections: 2,
 			maxIdleTime:        time.Second * 30,
 		},
-		apiUrl: "localhost:3000",
+		apiUrl:      "localhost:3000",
+		apiBaseURL:  apiBaseURL,
+		frontendURL: frontendURL,
 		mail: mailConfig{
-			exp: time.Hour * 24 * 3,
+			exp:       time.Hour * 24 * 3,
+			fromEmail: os.Getenv("MAIL_FROM"),
+			smtp: smtpConfig{
+				host:     os.Getenv("SMTP_HOST"),
+				port:     smtpPort,
+				username: os.Getenv("SMTP_USERNAME"),
+				password: os.Getenv("SMTP_PASSWORD"),
+			},
+			dir: "tmp/mail",
+		},
+		auth: authConfig{
+			token: tokenConfig{
+				secret: tokenSecret,
+				exp:    time.Minute * 15,
+				iss:    "gophersocial",
+				aud:    "gophersocial",
+			},
+			refresh: refreshConfig{
+				exp: time.Hour * 24 * 30,
+			},
+			passwordReset: passwordResetConfig{
+				exp: time.Hour,
+			},
+			password: passwordConfig{
+				minLength:      10,
+				requireUpper:   true,
+				requireLower:   true,
+				requireDigit:   true,
+				requireSymbol:  false,
+				rejectIdentity: true,
+				breachedDir:    os.Getenv("BREACHED_PASSWORDS_DIR"),
+			},
+			twoFactor: twoFactorConfig{
+				issuer:       "Gopher Social",
+				challengeExp: time.Minute * 5,
+			},
+			lockout: lockoutConfig{
+				threshold:   5,
+				ipThreshold: 20,
+				baseDelay:   time.Second,
+				maxDelay:    time.Minute,
+				duration:    time.Minute * 15,
+				window:      time.Hour,
+			},
+			oidc: oidcConfig{
+				issuer:       os.Getenv("OIDC_ISSUER"),
+				clientID:     os.Getenv("OIDC_CLIENT_ID"),
+				clientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+				redirectURL:  "http://localhost:3000/v1/auth/oidc/callback",
+				stateExp:     time.Minute * 10,
+			},
+			magicLink: magicLinkConfig{
+				exp:    time.Minute * 15,
+				limit:  3,
+				window: time.Hour,
+			},
+			pruneInterval: time.Hour,
+		},
+		invitation: invitationConfig{
+			sweepInterval:      time.Hour,
+			purgeInactiveAfter: time.Hour * 24 * 30,
+			resendLimit:        3,
+			resendWindow:       time.Hour,
+		},
+		emailChange: emailChangeConfig{
+			exp: time.Hour * 24,
+		},
+		accountDeletion: accountDeletionConfig{
+			grace:         time.Hour * 24 * 14,
+			sweepInterval: time.Hour,
+		},
+		export: exportConfig{
+			dir:           "tmp/exports",
+			archiveExp:    time.Hour * 24 * 7,
+			linkExp:       time.Hour,
+			buildTimeout:  time.Minute * 10,
+			sweepInterval: time.Hour,
+			linkSecret:    linkSecret,
+			workers:       2,
+			queueSize:     32,
+		},
+		comments: commentConfig{
+			maxDepth:          5,
+			pageSize:          20,
+			repliesPerComment: 3,
+			replyDepth:        2,
 		},
 	}
 
@@ -71,12 +195,84 @@ func main() {
 
 	store := store.NewStorage(db)
 
+	authenticator := auth.NewJWTAuthenticator(
+		config.auth.token.secret,
+		config.auth.token.aud,
+		config.auth.token.iss,
+	)
+
+	var mail mailer.Mailer
+	if config.mail.smtp.host != "" {
+		mail = mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     config.mail.smtp.host,
+			Port:     config.mail.smtp.port,
+			Username: config.mail.smtp.username,
+			Password: config.mail.smtp.password,
+			From:     config.mail.fromEmail,
+		})
+	} else {
+		mail, err = mailer.NewFileMailer(config.mail.dir)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Warnw("smtp is not configured, writing mail to disk", "dir", config.mail.dir)
+	}
+
+	var breachedPasswords *auth.BreachedPasswords
+	if config.auth.password.breachedDir != "" {
+		breachedPasswords, err = auth.NewBreachedPasswords(config.auth.password.breachedDir)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	var oidcProvider *auth.OIDCProvider
+	if config.auth.oidc.issuer != "" {
+		oidcProvider, err = auth.NewOIDCProvider(context.Background(), auth.OIDCConfig{
+			Issuer:       config.auth.oidc.issuer,
+			ClientID:     config.auth.oidc.clientID,
+			ClientSecret: config.auth.oidc.clientSecret,
+			RedirectURL:  config.auth.oidc.redirectURL,
+		})
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
 	app := &application{
-		config: *config,
-		store:  *store,
-		logger: logger,
+		config:        *config,
+		store:         *store,
+		logger:        logger,
+		authenticator: authenticator,
+		mailer:        mail,
+		oidc:          oidcProvider,
+
+		passwordPolicy: auth.PasswordPolicy{
+			MinLength:      config.auth.password.minLength,
+			RequireUpper:   config.auth.password.requireUpper,
+			RequireLower:   config.auth.password.requireLower,
+			RequireDigit:   config.auth.password.requireDigit,
+			RequireSymbol:  config.auth.password.requireSymbol,
+			RejectIdentity: config.auth.password.rejectIdentity,
+		},
+		breachedPasswords: breachedPasswords,
+		urlSigner:         auth.NewURLSigner(config.export.linkSecret),
+
+		activationLimiter: ratelimiter.NewFixedWindowLimiter(
+			config.invitation.resendLimit,
+			config.invitation.resendWindow,
+		),
+		magicLinkLimiter: ratelimiter.NewFixedWindowLimiter(
+			config.auth.magicLink.limit,
+			config.auth.magicLink.window,
+		),
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	app.startBackgroundJobs(ctx)
+
 	mux := app.mount()
 
 	if err := app.run(&mux); err != nil {
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+)
+
+// memoryStore keeps the rows the handler tests touch. Each fake embeds its
+// store interface and overrides only the methods the tests call, so calling
+// anything else panics on the nil interface. Identities are keyed by subject
+// since every test talks to a single provider.
+type memoryStore struct {
+	issuer string
+
+	mu            sync.Mutex
+	users         map[int]*store.User
+	identities    map[string]int
+	loginStates   map[string][2]string
+	refreshTokens map[string]int
+	challenges    map[int]int
+
+	challengeTokens map[string]int
+	totpSecrets     map[int]string
+	totpSteps       map[int]int64
+	recoveryCodes   map[int]map[string]bool
+	loginFailures   map[string]int
+	lockedUntil     map[string]time.Time
+}
+
+func newMemoryStore(issuer string) *memoryStore {
+	return &memoryStore{
+		issuer:        issuer,
+		users:         make(map[int]*store.User),
+		identities:    make(map[string]int),
+		loginStates:   make(map[string][2]string),
+		refreshTokens: make(map[string]int),
+		challenges:    make(map[int]int),
+
+		challengeTokens: make(map[string]int),
+		totpSecrets:     make(map[int]string),
+		totpSteps:       make(map[int]int64),
+		recoveryCodes:   make(map[int]map[string]bool),
+		loginFailures:   make(map[string]int),
+		lockedUntil:     make(map[string]time.Time),
+	}
+}
+
+func (memory *memoryStore) storage() store.Storage {
+	return store.Storage{
+		Users:         memoryUsers{memoryStore: memory},
+		Identities:    memoryIdentities{memoryStore: memory},
+		RefreshTokens: memoryRefreshTokens{memoryStore: memory},
+		TwoFactor:     memoryTwoFactor{memoryStore: memory},
+		LoginAttempts: memoryLoginAttempts{memoryStore: memory},
+	}
+}
+
+func (memory *memoryStore) getUser(userId int) (*store.User, error) {
+	user, found := memory.users[userId]
+	if !found {
+		return nil, store.ErrorNotFound
+	}
+
+	copied := *user
+	return &copied, nil
+}
+
+func (memory *memoryStore) link(user *store.User, identity *store.Identity) error {
+	if identity.Provider != memory.issuer {
+		return store.ErrorConflict
+	}
+
+	if _, found := memory.identities[identity.Subject]; found {
+		return store.ErrorConflict
+	}
+
+	identity.UserId = user.ID
+	memory.identities[identity.Subject] = user.ID
+
+	return nil
+}
+
+type memoryUsers struct {
+	store.Users
+	*memoryStore
+}
+
+func (memory memoryUsers) GetUserById(_ context.Context, userId int) (*store.User, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	return memory.getUser(userId)
+}
+
+func (memory memoryUsers) GetByEmail(_ context.Context, email string) (*store.User, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	for _, user := range memory.users {
+		if strings.EqualFold(user.Email, email) {
+			return memory.getUser(user.ID)
+		}
+	}
+
+	return nil, store.ErrorNotFound
+}
+
+func (memory memoryUsers) CancelDeletion(context.Context, int) (bool, error) {
+	return false, nil
+}
+
+type memoryIdentities struct {
+	store.Identities
+	*memoryStore
+}
+
+func (memory memoryIdentities) GetUser(_ context.Context, provider string, subject string) (*store.User, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	userId, found := memory.identities[subject]
+	if !found || provider != memory.issuer {
+		return nil, store.ErrorNotFound
+	}
+
+	return memory.getUser(userId)
+}
+
+func (memory memoryIdentities) Link(_ context.Context, user *store.User, identity *store.Identity) error {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	return memory.link(user, identity)
+}
+
+func (memory memoryIdentities) CreateUser(_ context.Context, user *store.User, identity *store.Identity) error {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	for id, existing := range memory.users {
+		if !strings.EqualFold(existing.Email, user.Email) {
+			continue
+		}
+
+		if existing.IsActive {
+			return store.ErrDuplicateEmail
+		}
+
+		delete(memory.users, id)
+	}
+
+	user.ID = len(memory.users) + 100
+	user.IsActive = true
+
+	if err := memory.link(user, identity); err != nil {
+		return err
+	}
+
+	copied := *user
+	memory.users[user.ID] = &copied
+
+	return nil
+}
+
+func (memory memoryIdentities) CreateLoginState(_ context.Context, state string, codeVerifier string, nonce string, _ time.Duration) error {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	memory.loginStates[state] = [2]string{codeVerifier, nonce}
+
+	return nil
+}
+
+func (memory memoryIdentities) ConsumeLoginState(_ context.Context, state string) (string, string, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	loginState, found := memory.loginStates[state]
+	if !found {
+		return "", "", store.ErrorNotFound
+	}
+
+	delete(memory.loginStates, state)
+
+	return loginState[0], loginState[1], nil
+}
+
+type memoryRefreshTokens struct {
+	store.RefreshTokens
+	*memoryStore
+}
+
+func (memory memoryRefreshTokens) Create(_ context.Context, token string, userId int, _ time.Duration) error {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	memory.refreshTokens[token] = userId
+
+	return nil
+}
+
+type memoryTwoFactor struct {
+	store.TwoFactor
+	*memoryStore
+}
+
+func (memory memoryTwoFactor) CreateChallenge(_ context.Context, userId int, token string, _ time.Duration) error {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	memory.challenges[userId]++
+	memory.challengeTokens[token] = userId
+
+	return nil
+}
+
+func (memory memoryTwoFactor) AttemptChallenge(_ context.Context, token string) (int, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	userId, found := memory.challengeTokens[token]
+	if !found {
+		return 0, store.ErrorNotFound
+	}
+
+	return userId, nil
+}
+
+func (memory memoryTwoFactor) ConsumeChallenge(_ context.Context, token string) (int, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	userId, found := memory.challengeTokens[token]
+	if !found {
+		return 0, store.ErrorNotFound
+	}
+
+	delete(memory.challengeTokens, token)
+
+	return userId, nil
+}
+
+func (memory memoryTwoFactor) GetSecret(_ context.Context, userId int) (string, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	secret, found := memory.totpSecrets[userId]
+	if !found {
+		return "", store.ErrorNotFound
+	}
+
+	return secret, nil
+}
+
+func (memory memoryTwoFactor) UseTOTPStep(_ context.Context, userId int, step int64) error {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	if last, found := memory.totpSteps[userId]; found && step <= last {
+		return store.ErrorConflict
+	}
+
+	memory.totpSteps[userId] = step
+
+	return nil
+}
+
+func (memory memoryTwoFactor) UseRecoveryCode(_ context.Context, userId int, code string) error {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	if !memory.recoveryCodes[userId][code] {
+		return store.ErrorNotFound
+	}
+
+	delete(memory.recoveryCodes[userId], code)
+
+	return nil
+}
+
+type memoryLoginAttempts struct {
+	store.LoginAttempts
+	*memoryStore
+}
+
+func (memory memoryLoginAttempts) LockedUntil(_ context.Context, keys ...string) (time.Time, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	var lockedUntil time.Time
+	for _, key := range keys {
+		if until := memory.lockedUntil[key]; until.After(time.Now()) && until.After(lockedUntil) {
+			lockedUntil = until
+		}
+	}
+
+	return lockedUntil, nil
+}
+
+func (memory memoryLoginAttempts) RecordFailure(_ context.Context, key string, _ time.Duration) (int, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	memory.loginFailures[key]++
+
+	return memory.loginFailures[key], nil
+}
+
+func (memory memoryLoginAttempts) Lock(_ context.Context, key string, until time.Time) error {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	memory.lockedUntil[key] = until
+
+	return nil
+}
+
+func (memory memoryLoginAttempts) Reset(_ context.Context, key string) error {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+
+	delete(memory.loginFailures, key)
+	delete(memory.lockedUntil, key)
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+type authUserKey string
+
+const (
+	authUserCtx  authUserKey = "authUserKey"
+	authTokenCtx authUserKey = "authTokenKey"
+)
+
+var (
+	errMissingAuthHeader   = errors.New("authorization header is missing")
+	errMalformedAuthHeader = errors.New("authorization header is malformed")
+	errRevokedToken        = errors.New("token has been revoked")
+	errInsufficientRole    = errors.New("user does not have the required role")
+)
+
+// authTokenMiddleware only accepts bearer access tokens, which makes routes
+// behind it unavailable to API keys.
+func (app *application) authTokenMiddleware(next http.Handler) http.Handler {
+	return app.authenticate("")(next)
+}
+
+// authenticate accepts a bearer access token or, when scope is set, an API
+// key that carries scope.
+func (app *application) authenticate(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				app.unauthorizedError(w, r, errMissingAuthHeader)
+				return
+			}
+
+			parts := strings.Split(header, " ")
+			if len(parts) != 2 {
+				app.unauthorizedError(w, r, errMalformedAuthHeader)
+				return
+			}
+
+			switch {
+			case parts[0] == "Bearer":
+				app.bearerAuth(w, r, next, parts[1])
+			case parts[0] == "ApiKey" && scope != "":
+				app.apiKeyAuth(w, r, next, parts[1], scope)
+			default:
+				app.unauthorizedError(w, r, errMalformedAuthHeader)
+			}
+		})
+	}
+}
+
+// authenticateByMethod requires the read scope from API keys on safe methods
+// and the write scope on everything else.
+func (app *application) authenticateByMethod(read string, write string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		readHandler := app.authenticate(read)(next)
+		writeHandler := app.authenticate(write)(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead:
+				readHandler.ServeHTTP(w, r)
+			default:
+				writeHandler.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+func (app *application) bearerAuth(w http.ResponseWriter, r *http.Request, next http.Handler, bearer string) {
+	token, err := app.authenticator.ValidateToken(bearer)
+	if err != nil {
+		app.unauthorizedError(w, r, err)
+		return
+	}
+
+	subject, err := token.Claims.GetSubject()
+	if err != nil {
+		app.unauthorizedError(w, r, err)
+		return
+	}
+
+	userId, err := strconv.Atoi(subject)
+	if err != nil {
+		app.unauthorizedError(w, r, err)
+		return
+	}
+
+	issuedAt, err := token.Claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		app.unauthorizedError(w, r, errMalformedAuthHeader)
+		return
+	}
+
+	revoked, err := app.store.Revocations.IsRevoked(r.Context(), userId, getTokenId(token), issuedAt.Time)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if revoked {
+		app.unauthorizedError(w, r, errRevokedToken)
+		return
+	}
+
+	user, err := app.getAuthUser(w, r, userId)
+	if err != nil {
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), authUserCtx, user)
+	ctx = context.WithValue(ctx, authTokenCtx, token)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func (app *application) apiKeyAuth(
+	w http.ResponseWriter,
+	r *http.Request,
+	next http.Handler,
+	plainKey string,
+	scope string,
+) {
+	key, err := app.store.APIKeys.Authenticate(r.Context(), hashToken(plainKey))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.unauthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if !slices.Contains(key.Scopes, scope) {
+		app.forbiddenError(w, r, fmt.Errorf("api key is missing the %s scope", scope))
+		return
+	}
+
+	user, err := app.getAuthUser(w, r, key.UserId)
+	if err != nil {
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), authUserCtx, user)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// getAuthUser loads the authenticated user and writes the error response itself when that fails.
+func (app *application) getAuthUser(w http.ResponseWriter, r *http.Request, userId int) (*store.User, error) {
+	user, err := app.store.Users.GetUserById(r.Context(), userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.unauthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// requireRole only lets through users with at least role. As with post
+// policies, privileges require two-factor to be enabled.
+func (app *application) requireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := getAuthUserFromCtx(r)
+
+			if !user.HasRole(role) || !user.TwoFactorEnabled {
+				app.forbiddenError(w, r, errInsufficientRole)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func getAuthUserFromCtx(r *http.Request) *store.User {
+	user, _ := r.Context().Value(authUserCtx).(*store.User)
+	return user
+}
+
+func getAuthTokenFromCtx(r *http.Request) *jwt.Token {
+	token, _ := r.Context().Value(authTokenCtx).(*jwt.Token)
+	return token
+}
+
+func getTokenId(token *jwt.Token) string {
+	claims, _ := token.Claims.(jwt.MapClaims)
+	jti, _ := claims["jti"].(string)
+	return jti
+}
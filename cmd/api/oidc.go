@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/auth"
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+	"github.com/google/uuid"
+)
+
+const maxOIDCUsernameLength = 80
+
+var (
+	errInvalidLoginState  = errors.New("oidc login state is invalid or expired")
+	errUnverifiedEmail    = errors.New("oidc provider did not verify the email address")
+	errMissingOIDCCode    = errors.New("oidc callback is missing the authorization code")
+	errOIDCLoginCancelled = errors.New("oidc login was not approved")
+)
+
+// OIDCLoginHandler godoc
+//
+//	@Summary		Sign in with OIDC
+//	@Description	Redirects to the OpenID Connect provider using the authorization code flow with PKCE
+//	@Tags			auth
+//	@Success		302
+//	@Failure		500	{object}	map[string]string
+//	@Router			/auth/oidc/login [get]
+func (app *application) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	state, hashState := generateToken()
+	nonce := uuid.New().String()
+	verifier := auth.GenerateCodeVerifier()
+
+	if err := app.store.Identities.CreateLoginState(
+		r.Context(),
+		hashState,
+		verifier,
+		nonce,
+		app.config.auth.oidc.stateExp,
+	); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, app.oidc.AuthCodeURL(state, nonce, verifier), http.StatusFound)
+}
+
+// OIDCCallbackHandler godoc
+//
+//	@Summary		Complete OIDC sign in
+//	@Description	Exchanges the authorization code, links the external identity and issues tokens. Accounts are matched or created by verified email
+//	@Tags			auth
+//	@Produce		json
+//	@Param			code	query		string	true	"Authorization code"
+//	@Param			state	query		string	true	"State returned by the provider"
+//	@Success		201		{object}	TokenResponse
+//	@Success		202		{object}	TwoFactorChallenge
+//	@Failure		400		{object}	map[string]string
+//	@Failure		401		{object}	map[string]string
+//	@Failure		403		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Router			/auth/oidc/callback [get]
+func (app *application) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("error") != "" {
+		app.unauthorizedError(w, r, errOIDCLoginCancelled)
+		return
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		app.badRequestError(w, r, errMissingOIDCCode)
+		return
+	}
+
+	verifier, nonce, err := app.store.Identities.ConsumeLoginState(r.Context(), hashToken(query.Get("state")))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.unauthorizedError(w, r, errInvalidLoginState)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	identity, err := app.oidc.Exchange(r.Context(), code, nonce, verifier)
+	if err != nil {
+		app.unauthorizedError(w, r, err)
+		return
+	}
+
+	user, err := app.store.Identities.GetUser(r.Context(), identity.Issuer, identity.Subject)
+	if errors.Is(err, store.ErrorNotFound) {
+		user, err = app.linkOIDCIdentity(r, identity)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, errUnverifiedEmail):
+			app.forbiddenError(w, r, err)
+		case errors.Is(err, store.ErrorConflict),
+			errors.Is(err, store.ErrDuplicateEmail),
+			errors.Is(err, store.ErrDuplicateUsername):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.completeLogin(w, r, user)
+}
+
+// linkOIDCIdentity attaches a first-time identity to the activated account
+// with the same verified email, or registers a new account for it.
+func (app *application) linkOIDCIdentity(r *http.Request, identity *auth.OIDCIdentity) (*store.User, error) {
+	if !identity.EmailVerified || identity.Email == "" {
+		return nil, errUnverifiedEmail
+	}
+
+	link := &store.Identity{
+		Provider: identity.Issuer,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+
+	// An account that was never activated is replaced by CreateUser instead of
+	// linked, so a password set by whoever registered the address first
+	// cannot be used to sign in to it.
+	user, err := app.store.Users.GetByEmail(r.Context(), identity.Email)
+	switch {
+	case err == nil && user.IsActive:
+		if err := app.store.Identities.Link(r.Context(), user, link); err != nil {
+			return nil, err
+		}
+		return user, nil
+	case err != nil && !errors.Is(err, store.ErrorNotFound):
+		return nil, err
+	}
+
+	username, err := oidcUsername(identity.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	user = &store.User{
+		UserName: username,
+		Email:    identity.Email,
+	}
+
+	// The account can only be signed into through the provider until the user
+	// resets the password.
+	if err := user.Password.Set(password); err != nil {
+		return nil, err
+	}
+
+	if err := app.store.Identities.CreateUser(r.Context(), user, link); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// oidcUsername derives a username from the local part of email with a random
+// suffix so it does not collide with existing accounts.
+func oidcUsername(email string) (string, error) {
+	local, _, _ := strings.Cut(email, "@")
+
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			return r
+		default:
+			return -1
+		}
+	}, local)
+
+	if len(name) > maxOIDCUsernameLength {
+		name = name[:maxOIDCUsernameLength]
+	}
+
+	if name == "" {
+		name = "user"
+	}
+
+	suffix, err := randomHex(3)
+	if err != nil {
+		return "", err
+	}
+
+	return name + "_" + suffix, nil
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
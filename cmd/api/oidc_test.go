@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/auth"
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/auth/oidctest"
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+	"go.uber.org/zap"
+)
+
+const (
+	testClientID     = "gophersocial-test"
+	testClientSecret = "oidc-test-client-secret"
+	testRedirectURL  = "http://localhost:3000/v1/auth/oidc/callback"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	existing := &store.User{ID: 7, UserName: "gopher", Email: "gopher@example.com", IsActive: true}
+	unactivated := &store.User{ID: 7, UserName: "squatter", Email: "gopher@example.com"}
+
+	tests := []struct {
+		name       string
+		user       oidctest.User
+		users      []*store.User
+		identities map[string]int
+		wantStatus int
+		// wantUserId is the account the identity ends up linked to, or 0 for
+		// a newly created one.
+		wantUserId int
+	}{
+		{
+			name:       "creates an account",
+			user:       oidctest.User{Subject: "new", Email: "new.gopher@example.com", EmailVerified: true},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "links a verified email",
+			user:       oidctest.User{Subject: "linked", Email: "Gopher@example.com", EmailVerified: true},
+			users:      []*store.User{existing},
+			wantStatus: http.StatusCreated,
+			wantUserId: existing.ID,
+		},
+		{
+			// Whoever registered the address never confirmed it, so their
+			// account and password are dropped instead of being activated.
+			name:       "replaces an unactivated registration",
+			user:       oidctest.User{Subject: "linked", Email: "gopher@example.com", EmailVerified: true},
+			users:      []*store.User{unactivated},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "signs in a linked identity",
+			user:       oidctest.User{Subject: "returning", Email: "changed@example.com"},
+			users:      []*store.User{{ID: 9, UserName: "returning", Email: "returning@example.com", IsActive: true}},
+			identities: map[string]int{"returning": 9},
+			wantStatus: http.StatusCreated,
+			wantUserId: 9,
+		},
+		{
+			name:       "asks for the second factor",
+			user:       oidctest.User{Subject: "linked", Email: "gopher@example.com", EmailVerified: true},
+			users:      []*store.User{{ID: 8, UserName: "guarded", Email: "gopher@example.com", IsActive: true, TwoFactorEnabled: true}},
+			wantStatus: http.StatusAccepted,
+			wantUserId: 8,
+		},
+		{
+			name:       "rejects an unverified email",
+			user:       oidctest.User{Subject: "unverified", Email: "gopher@example.com"},
+			users:      []*store.User{existing},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider, err := oidctest.NewProvider(testClientID, testClientSecret, test.user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer provider.Close()
+
+			memory := newMemoryStore(provider.Issuer())
+			for _, user := range test.users {
+				copied := *user
+				memory.users[user.ID] = &copied
+			}
+			for subject, userId := range test.identities {
+				memory.identities[subject] = userId
+			}
+
+			app := newOIDCTestApp(t, provider, memory)
+
+			response := oidcLogin(t, app)
+			if response.Code != test.wantStatus {
+				t.Fatalf("callback status = %d, want %d: %s", response.Code, test.wantStatus, response.Body)
+			}
+
+			userId, linked := memory.identities[test.user.Subject]
+
+			switch test.wantStatus {
+			case http.StatusForbidden:
+				if linked || len(memory.users) != len(test.users) {
+					t.Errorf("unverified email created %d users and linked %v", len(memory.users)-len(test.users), linked)
+				}
+				return
+			case http.StatusAccepted:
+				if memory.challenges[test.wantUserId] == 0 {
+					t.Errorf("no two-factor challenge was created for user %d", test.wantUserId)
+				}
+				if len(memory.refreshTokens) != 0 {
+					t.Errorf("issued %d refresh tokens before the second factor", len(memory.refreshTokens))
+				}
+			case http.StatusCreated:
+				var body struct {
+					Data TokenResponse `json:"data"`
+				}
+				if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+					t.Fatal(err)
+				}
+
+				if body.Data.AccessToken == "" {
+					t.Error("callback did not return an access token")
+				}
+				if memory.refreshTokens[hashToken(body.Data.RefreshToken)] != userId {
+					t.Error("refresh token was not stored for the signed in user")
+				}
+			}
+
+			if !linked {
+				t.Fatal("identity was not linked")
+			}
+
+			if test.wantUserId != 0 && userId != test.wantUserId {
+				t.Errorf("identity linked to user %d, want %d", userId, test.wantUserId)
+			}
+
+			user := memory.users[userId]
+			if test.wantUserId == 0 {
+				localPart, _, _ := strings.Cut(test.user.Email, "@")
+				if user.Email != test.user.Email || !strings.HasPrefix(user.UserName, localPart+"_") {
+					t.Errorf("created user %q <%s>", user.UserName, user.Email)
+				}
+
+				for _, existing := range test.users {
+					if _, found := memory.users[existing.ID]; found && !existing.IsActive {
+						t.Errorf("unactivated user %d was kept", existing.ID)
+					}
+				}
+			}
+			if !user.IsActive {
+				t.Error("signed in user is not active")
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackRejectsReusedState(t *testing.T) {
+	provider, err := oidctest.NewProvider(testClientID, testClientSecret, oidctest.User{
+		Subject:       "gopher",
+		Email:         "gopher@example.com",
+		EmailVerified: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Close()
+
+	app := newOIDCTestApp(t, provider, newMemoryStore(provider.Issuer()))
+
+	callback := oidcAuthorize(t, app)
+
+	if response := oidcCallback(app, callback); response.Code != http.StatusCreated {
+		t.Fatalf("first callback status = %d, want %d: %s", response.Code, http.StatusCreated, response.Body)
+	}
+
+	if response := oidcCallback(app, callback); response.Code != http.StatusUnauthorized {
+		t.Errorf("reused callback status = %d, want %d", response.Code, http.StatusUnauthorized)
+	}
+}
+
+func newOIDCTestApp(t *testing.T, provider *oidctest.Provider, memory *memoryStore) *application {
+	t.Helper()
+
+	oidcProvider, err := auth.NewOIDCProvider(context.Background(), auth.OIDCConfig{
+		Issuer:       provider.Issuer(),
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		RedirectURL:  testRedirectURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{
+		logger:        zap.NewNop().Sugar(),
+		authenticator: auth.NewJWTAuthenticator("jwt-test-secret", "gophersocial", "gophersocial"),
+		oidc:          oidcProvider,
+		store:         memory.storage(),
+	}
+	app.config.auth.token.exp = time.Minute * 15
+	app.config.auth.refresh.exp = time.Hour
+	app.config.auth.oidc.stateExp = time.Minute * 10
+	app.config.auth.twoFactor.challengeExp = time.Minute * 5
+
+	return app
+}
+
+// oidcLogin runs the whole flow: it starts the login, lets the provider
+// approve it and hands the redirect back to the callback handler.
+func oidcLogin(t *testing.T, app *application) *httptest.ResponseRecorder {
+	t.Helper()
+
+	return oidcCallback(app, oidcAuthorize(t, app))
+}
+
+// oidcAuthorize starts a login and returns the callback URL the provider
+// redirects back to.
+func oidcAuthorize(t *testing.T, app *application) *url.URL {
+	t.Helper()
+
+	login := httptest.NewRecorder()
+	app.oidcLoginHandler(login, httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/login", nil))
+
+	if login.Code != http.StatusFound {
+		t.Fatalf("login status = %d, want %d: %s", login.Code, http.StatusFound, login.Body)
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	response, err := client.Get(login.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d, want %d", response.StatusCode, http.StatusFound)
+	}
+
+	callback, err := url.Parse(response.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(callback.String(), testRedirectURL+"?") {
+		t.Fatalf("provider redirected to %s, want %s", callback, testRedirectURL)
+	}
+
+	return callback
+}
+
+func oidcCallback(app *application, callback *url.URL) *httptest.ResponseRecorder {
+	response := httptest.NewRecorder()
+	app.oidcCallbackHandler(response, httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil))
+
+	return response
+}
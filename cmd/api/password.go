@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+)
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+// ForgotPasswordHandler godoc
+//
+//	@Summary		Request a password reset
+//	@Description	Emails a single-use password reset link for the account. The link opens a frontend page that asks for the new password. Always responds 202 so emails cannot be enumerated
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body	ForgotPasswordPayload	true	"Account email"
+//	@Success		202
+//	@Failure		400	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Router			/auth/password/forgot [post]
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(&payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	if err != nil && !errors.Is(err, store.ErrorNotFound) {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if user != nil && user.IsActive {
+		plainToken, hashToken := generateToken()
+
+		if err := app.store.Users.CreatePasswordReset(r.Context(), user.ID, hashToken, app.config.auth.passwordReset.exp); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if err := app.sendPasswordResetEmail(user, plainToken); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ResetPasswordHandler godoc
+//
+//	@Summary		Reset a password
+//	@Description	Sets a new password using a reset token and revokes every existing session. The emailed link opens a frontend page that calls this endpoint with the new password
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body	ResetPasswordPayload	true	"Reset token and new password"
+//	@Success		204
+//	@Failure		400	{object}	map[string]string
+//	@Failure		422	{object}	map[string]interface{}
+//	@Failure		500	{object}	map[string]string
+//	@Router			/auth/password/reset [post]
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResetPasswordPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(&payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user, err := app.store.Users.GetByPasswordReset(r.Context(), payload.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	violations, err := app.checkPassword(payload.Password, user.UserName, user.Email)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if len(violations) > 0 {
+		app.failedValidationError(w, r, map[string][]string{"password": violations})
+		return
+	}
+
+	user, err = app.store.Users.ResetPassword(r.Context(), payload.Token, payload.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.revokeSessions(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// checkPassword returns every password policy violation, including a match in
+// the breached password corpus when one is configured.
+func (app *application) checkPassword(password string, username string, email string) ([]string, error) {
+	violations := app.passwordPolicy.Check(password, username, email)
+
+	if app.breachedPasswords != nil {
+		breached, err := app.breachedPasswords.IsBreached(password)
+		if err != nil {
+			return nil, err
+		}
+
+		if breached {
+			violations = append(violations, "has appeared in a data breach, choose a different one")
+		}
+	}
+
+	return violations, nil
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/auth"
 	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
 	"github.com/go-chi/chi/v5"
 )
@@ -14,6 +15,11 @@ type postKey string
 
 const postCtx postKey = "postKey"
 
+var (
+	errPostForbidden       = errors.New("user is not allowed to modify this post")
+	errCommentsUnavailable = errors.New("comments could not be loaded")
+)
+
 type CreatePostPayload struct {
 	Title   string   `json:"title" validate:"required,max=100"`
 	Content string   `json:"content"`
@@ -30,7 +36,9 @@ type CreatePostPayload struct {
 //	@Param			payload	body		CreatePostPayload	true	"Post payload"
 //	@Success		200		{object}	map[string]interface{}
 //	@Failure		400		{string}	string	"Bad request"
+//	@Failure		401		{object}	map[string]string
 //	@Failure		500		{object}	map[string]string
+//	@Security		ApiKeyAuth
 //	@Router			/post [post]
 func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request) {
 	var payload CreatePostPayload
@@ -46,11 +54,13 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	user := getAuthUserFromCtx(r)
+
 	post := &store.Post{
 		Content: payload.Content,
 		Title:   payload.Title,
 		Tags:    payload.Tags,
-		UserId:  1,
+		UserId:  user.ID,
 	}
 
 	err = app.store.Posts.Create(r.Context(), post)
@@ -64,30 +74,52 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 
 }
 
+// PostDetail is a post with the first page of its comments. When the
+// comments cannot be loaded the post is still returned, with Comments left
+// null, Partial set and the reason in Errors.
+type PostDetail struct {
+	*store.Post
+	Comments *store.Page[*store.Comment] `json:"comments"`
+	Partial  bool                        `json:"partial"`
+	Errors   []string                    `json:"errors,omitempty"`
+}
+
 // GetPostHandler godoc
 //
 //	@Summary		Get post details
-//	@Description	Returns post details by the provided id
+//	@Description	Returns post details by the provided id, with the first page of its comments. Use next_cursor with the comments endpoint for the rest. If the comments cannot be loaded, partial is true and errors says why
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			postId	path		int	true	"Post ID"
-//	@Success		200		{object}	map[string]interface{}
+//	@Param			postId	path		int		true	"Post ID"
+//	@Param			view	query		string	false	"nested or flat"	default(nested)
+//	@Success		200		{object}	PostDetail
 //	@Failure		400		{string}	string	"Bad request"
+//	@Failure		404		{object}	map[string]string
 //	@Failure		500		{object}	map[string]string
+//	@Security		ApiKeyAuth
 //	@Router			/post/{postId} [get]
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
-	post := getPostFromCtx(r)
+	view, err := parseCommentView(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
 
-	comments, err := app.store.Comments.GetByPostId(r.Context(), post.ID)
+	detail := PostDetail{Post: getPostFromCtx(r)}
 
+	query := store.CursorQuery{Limit: app.config.comments.pageSize}
+
+	detail.Comments, err = app.getCommentPage(r, detail.Post, query, view)
 	if err != nil {
-		app.logger.Errorf("internal server error %s path: %s error:%s", r.Method, r.URL.Path, err.Error())
-	} else {
-		post.Comments = *comments
+		app.logger.Errorw("cannot load post comments", "post_id", detail.Post.ID, "error", err.Error())
+		detail.Partial = true
+		detail.Errors = append(detail.Errors, errCommentsUnavailable.Error())
 	}
 
-	app.jsonResponse(w, http.StatusOK, post)
+	if err := app.jsonResponse(w, http.StatusOK, detail); err != nil {
+		app.internalServerError(w, r, err)
+	}
 }
 
 // DeletePostHandler godoc
@@ -100,7 +132,9 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 //	@Param			postId	path		int	true	"Post ID"
 //	@Success		200		{object}	map[string]interface{}
 //	@Failure		400		{string}	string	"Bad request"
+//	@Failure		403		{object}	map[string]string
 //	@Failure		500		{object}	map[string]string
+//	@Security		ApiKeyAuth
 //	@Router			/post/{postId} [delete]
 func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
@@ -138,7 +172,9 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 	//	@Param			payload	body		object	true	"Update post payload"
 	//	@Success		201		{object}	map[string]interface{}
 	//	@Failure		400		{string}	string	"Bad request"
+	//	@Failure		403		{object}	map[string]string
 	//	@Failure		500		{object}	map[string]string
+	//	@Security		ApiKeyAuth
 	//	@Router			/post/{postId} [patch]
 
 	if err := readJson(w, r, &payload); err != nil {
@@ -195,6 +231,25 @@ func (app *application) postMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		author, err := app.store.Users.GetUserById(ctx, post.UserId)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		// Posts of private accounts and of blocked users are hidden as if they
+		// did not exist.
+		visible, err := app.canViewPosts(r, author)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !visible {
+			app.notFoundError(w, r, store.ErrorNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, postCtx, post)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -206,3 +261,17 @@ func getPostFromCtx(r *http.Request) *store.Post {
 	post, _ := r.Context().Value(postCtx).(*store.Post)
 	return post
 }
+
+func (app *application) checkPostPolicy(policy auth.PostPolicy, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := getAuthUserFromCtx(r)
+		post := getPostFromCtx(r)
+
+		if !policy(user, post) {
+			app.forbiddenError(w, r, errPostForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/auth"
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	errInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	errTwoFactorNotEnrolled = errors.New("two-factor enrollment has not been started")
+	errTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+)
+
+type TwoFactorEnrollment struct {
+	Secret     string `json:"secret"`
+	OtpAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorCodePayload struct {
+	Code string `json:"code" validate:"required,max=20"`
+}
+
+type TwoFactorChallenge struct {
+	Challenge string    `json:"challenge"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type TwoFactorVerifyPayload struct {
+	Challenge string `json:"challenge" validate:"required"`
+	Code      string `json:"code" validate:"required,max=20"`
+}
+
+// EnrollTwoFactorHandler godoc
+//
+//	@Summary		Start two-factor enrollment
+//	@Description	Generates a TOTP secret and returns it with an otpauth URI. It takes effect after confirmation
+//	@Tags			auth
+//	@Produce		json
+//	@Success		201	{object}	TwoFactorEnrollment
+//	@Failure		401	{object}	map[string]string
+//	@Failure		409	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/auth/2fa/enroll [post]
+func (app *application) enrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := getAuthUserFromCtx(r)
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.TwoFactor.SetPendingSecret(r.Context(), user.ID, secret); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorConflict):
+			app.conflictError(w, r, errTwoFactorEnabled)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	enrollment := TwoFactorEnrollment{
+		Secret:     secret,
+		OtpAuthURI: auth.TOTPURI(app.config.auth.twoFactor.issuer, user.Email, secret),
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, enrollment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ConfirmTwoFactorHandler godoc
+//
+//	@Summary		Confirm two-factor enrollment
+//	@Description	Enables two-factor authentication with a code from the authenticator app and returns single-use recovery codes
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		TwoFactorCodePayload	true	"Authenticator code"
+//	@Success		200		{array}		string
+//	@Failure		400		{object}	map[string]string
+//	@Failure		401		{object}	map[string]string
+//	@Failure		409		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/auth/2fa/confirm [post]
+func (app *application) confirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorCodePayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(&payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+
+	if user.TwoFactorEnabled {
+		app.conflictError(w, r, errTwoFactorEnabled)
+		return
+	}
+
+	secret, err := app.store.TwoFactor.GetSecret(r.Context(), user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.badRequestError(w, r, errTwoFactorNotEnrolled)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	step, valid := auth.MatchTOTP(secret, payload.Code, time.Now())
+	if !valid {
+		app.badRequestError(w, r, errInvalidTwoFactorCode)
+		return
+	}
+
+	// The code used to confirm enrollment cannot be used again to log in.
+	if err := app.store.TwoFactor.UseTOTPStep(r.Context(), user.ID, step); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorConflict):
+			app.badRequestError(w, r, errInvalidTwoFactorCode)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.TwoFactor.Enable(r.Context(), user.ID, hashes); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, codes); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// VerifyTwoFactorHandler godoc
+//
+//	@Summary		Complete a two-factor login
+//	@Description	Exchanges a login challenge and an authenticator or recovery code for a token pair. Wrong codes count towards the account lockout
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		TwoFactorVerifyPayload	true	"Challenge and code"
+//	@Success		201		{object}	TokenResponse
+//	@Failure		400		{object}	map[string]string
+//	@Failure		401		{object}	map[string]string
+//	@Failure		429		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Router			/auth/2fa/verify [post]
+func (app *application) verifyTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorVerifyPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(&payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	challenge := hashToken(payload.Challenge)
+
+	userId, err := app.store.TwoFactor.AttemptChallenge(r.Context(), challenge)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.unauthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.store.Users.GetUserById(r.Context(), userId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	retryAfter, err := app.loginRetryAfter(r, user.Email)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if retryAfter > 0 {
+		app.rateLimitExceededError(w, r, retryAfter)
+		return
+	}
+
+	valid, err := app.checkTwoFactorCode(r, userId, payload.Code)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !valid {
+		// Failures count against the account like wrong passwords, so codes
+		// cannot be guessed by starting fresh challenges.
+		if err := app.recordLoginFailure(r, user.Email); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		app.unauthorizedError(w, r, errInvalidTwoFactorCode)
+		return
+	}
+
+	if _, err := app.store.TwoFactor.ConsumeChallenge(r.Context(), challenge); err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.unauthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.resetLoginFailures(r, user.Email); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens, err := app.issueTokens(r.Context(), userId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// checkTwoFactorCode accepts either a current TOTP code or an unused recovery
+// code. TOTP codes are only accepted once.
+func (app *application) checkTwoFactorCode(r *http.Request, userId int, code string) (bool, error) {
+	secret, err := app.store.TwoFactor.GetSecret(r.Context(), userId)
+	if err != nil {
+		return false, err
+	}
+
+	if step, valid := auth.MatchTOTP(secret, code, time.Now()); valid {
+		err := app.store.TwoFactor.UseTOTPStep(r.Context(), userId, step)
+		switch {
+		case err == nil:
+			return true, nil
+		case errors.Is(err, store.ErrorConflict):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	err = app.store.TwoFactor.UseRecoveryCode(r.Context(), userId, hashToken(strings.ToLower(code)))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, store.ErrorNotFound):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// createTwoFactorChallenge starts the second step of a login for a user with two-factor enabled.
+func (app *application) createTwoFactorChallenge(r *http.Request, userId int) (*TwoFactorChallenge, error) {
+	plainToken, hashToken := generateToken()
+	exp := app.config.auth.twoFactor.challengeExp
+
+	if err := app.store.TwoFactor.CreateChallenge(r.Context(), userId, hashToken, exp); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorChallenge{
+		Challenge: plainToken,
+		ExpiresAt: time.Now().Add(exp),
+	}, nil
+}
+
+// generateRecoveryCodes returns the plain codes shown to the user once and the hashes that get stored.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range recoveryCodeCount {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+
+		code := hex.EncodeToString(buf)
+		codes[i] = code
+		hashes[i] = hashToken(code)
+	}
+
+	return codes, hashes, nil
+}
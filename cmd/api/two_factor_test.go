@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/auth"
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+	"go.uber.org/zap"
+)
+
+func TestTwoFactorFailuresLockTheAccount(t *testing.T) {
+	const (
+		email     = "gopher@example.com"
+		password  = "correct horse battery staple"
+		threshold = 3
+	)
+
+	user := &store.User{ID: 1, UserName: "gopher", Email: email, IsActive: true, TwoFactorEnabled: true}
+	if err := user.Password.Set(password); err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	memory := newMemoryStore("")
+	memory.users[user.ID] = user
+	memory.totpSecrets[user.ID] = secret
+
+	app := &application{
+		logger:        zap.NewNop().Sugar(),
+		authenticator: auth.NewJWTAuthenticator("jwt-test-secret", "gophersocial", "gophersocial"),
+		store:         memory.storage(),
+	}
+	app.config.auth.twoFactor.challengeExp = time.Minute * 5
+	// The backoff between failures expires straight away so only the lockout
+	// at threshold can turn logins away.
+	app.config.auth.lockout = lockoutConfig{
+		threshold:   threshold,
+		ipThreshold: 100,
+		baseDelay:   time.Nanosecond,
+		maxDelay:    time.Nanosecond,
+		duration:    time.Hour,
+		window:      time.Hour,
+	}
+
+	for attempt := 1; attempt <= threshold; attempt++ {
+		login := postJson(app.createTokenHandler, CreateUserTokenPayload{Email: email, Password: password})
+		if login.Code != http.StatusAccepted {
+			t.Fatalf("login %d status = %d, want %d: %s", attempt, login.Code, http.StatusAccepted, login.Body)
+		}
+
+		var body struct {
+			Data TwoFactorChallenge `json:"data"`
+		}
+		if err := json.NewDecoder(login.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		verify := postJson(app.verifyTwoFactorHandler, TwoFactorVerifyPayload{
+			Challenge: body.Data.Challenge,
+			Code:      "not-a-code",
+		})
+		if verify.Code != http.StatusUnauthorized {
+			t.Fatalf("code %d status = %d, want %d: %s", attempt, verify.Code, http.StatusUnauthorized, verify.Body)
+		}
+	}
+
+	login := postJson(app.createTokenHandler, CreateUserTokenPayload{Email: email, Password: password})
+	if login.Code != http.StatusTooManyRequests {
+		t.Errorf("login after %d wrong codes status = %d, want %d", threshold, login.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestVerifyTwoFactorHandler(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := auth.GenerateTOTPCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	memory := newMemoryStore("")
+	memory.users[1] = &store.User{ID: 1, Email: "gopher@example.com", IsActive: true, TwoFactorEnabled: true}
+	memory.totpSecrets[1] = secret
+	memory.recoveryCodes[1] = map[string]bool{hashes[0]: true}
+
+	app := &application{
+		logger:        zap.NewNop().Sugar(),
+		authenticator: auth.NewJWTAuthenticator("jwt-test-secret", "gophersocial", "gophersocial"),
+		store:         memory.storage(),
+	}
+	app.config.auth.lockout = lockoutConfig{
+		threshold:   100,
+		ipThreshold: 100,
+		baseDelay:   time.Nanosecond,
+		maxDelay:    time.Nanosecond,
+		duration:    time.Hour,
+		window:      time.Hour,
+	}
+
+	// The steps run in order against the same account, each with a fresh
+	// challenge.
+	steps := []struct {
+		name string
+		code string
+		want int
+	}{
+		{"current code", code, http.StatusCreated},
+		{"replayed code", code, http.StatusUnauthorized},
+		{"wrong code", "not-a-code", http.StatusUnauthorized},
+		{"recovery code in upper case", strings.ToUpper(codes[0]), http.StatusCreated},
+		{"used recovery code", codes[0], http.StatusUnauthorized},
+		{"recovery code that was never issued", codes[1], http.StatusUnauthorized},
+	}
+
+	for _, step := range steps {
+		challenge, hash := generateToken()
+		memory.challengeTokens[hash] = 1
+
+		response := postJson(app.verifyTwoFactorHandler, TwoFactorVerifyPayload{Challenge: challenge, Code: step.code})
+		if response.Code != step.want {
+			t.Errorf("%s: status = %d, want %d: %s", step.name, response.Code, step.want, response.Body)
+		}
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("generated %d codes and %d hashes, want %d", len(codes), len(hashes), recoveryCodeCount)
+	}
+
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		if code != strings.ToLower(code) {
+			t.Errorf("code %q is not lower case, so it would not match its hash once normalised", code)
+		}
+
+		if hashes[i] != hashToken(code) {
+			t.Errorf("hash of code %d = %q, want %q", i, hashes[i], hashToken(code))
+		}
+
+		if hashes[i] == code {
+			t.Errorf("code %d is stored in plain text", i)
+		}
+
+		if seen[code] {
+			t.Errorf("code %q was generated twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func postJson(handler http.HandlerFunc, payload any) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(payload)
+
+	response := httptest.NewRecorder()
+	handler(response, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+
+	return response
+}
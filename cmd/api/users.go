@@ -2,14 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/auth"
 	"github.com/Dinuka-Dilshan/go-web-dev/internal/store"
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 )
 
+var (
+	errAlreadyFollowing = errors.New("already following this user")
+	errSameEmail        = errors.New("new email is the same as the current one")
+)
+
 type UserKey string
 
 const userCtx UserKey = "userKey"
@@ -17,23 +26,51 @@ const userCtx UserKey = "userKey"
 // GetUserHandler godoc
 //
 //	@Summary		Get user details
-//	@Description	Returns user details by the provided id
+//	@Description	Returns the profile of the user with the provided id. The email is only shown to the user and to admins
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userId	path		int	true	"User ID"
-//	@Success		200		{object}	map[string]interface{}
+//	@Success		200		{object}	store.Profile
 //	@Failure		400		{string}	string	"Bad request"
+//	@Failure		401		{object}	map[string]string
+//	@Failure		404		{object}	map[string]string
 //	@Failure		500		{object}	map[string]string
+//	@Security		ApiKeyAuth
 //	@Router			/users/{userId} [get]
 func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	user := getUserFromCtx(r)
 
-	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+	profile, err := app.getProfile(r, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, profile); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
+// getProfile loads the profile of user as seen by the authenticated user.
+func (app *application) getProfile(r *http.Request, user *store.User) (*store.Profile, error) {
+	profile, err := app.store.Users.GetProfile(r.Context(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !auth.CanViewEmail(getAuthUserFromCtx(r), user) {
+		profile.Email = ""
+	}
+
+	return profile, nil
+}
+
 func (app *application) userContextMiddleWare(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		param := chi.URLParam(r, "userId")
@@ -61,43 +98,48 @@ func (app *application) userContextMiddleWare(next http.Handler) http.Handler {
 	})
 }
 
-type FollowUser struct {
-	UserID int `json:"user_id"`
-}
-
 // FollowUserHandler godoc
 //
 //	@Summary		Follow a user
-//	@Description	Follow a user by the provided user id
+//	@Description	Follow a user by the provided user id. Following a private account sends a follow request instead and responds 202
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
-//	@Param			userId	path		int			true	"User ID"
-//	@Param			payload	body		FollowUser	true	"Follow payload"
+//	@Param			userId	path		int	true	"User ID"
 //	@Success		200		{object}	map[string]interface{}
+//	@Success		202		{object}	FollowRequestStatus
 //	@Failure		400		{string}	string	"Bad request"
+//	@Failure		401		{object}	map[string]string
+//	@Failure		403		{object}	map[string]string
 //	@Failure		409		{string}	string	"Conflict"
 //	@Failure		500		{object}	map[string]string
-//	@Router			/users/{userId}/follow [post]
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/follow [put]
 func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
-	var payload FollowUser
-	if err := readJson(w, r, &payload); err != nil {
-		app.badRequestError(w, r, err)
+	user := getAuthUserFromCtx(r)
+	followUser := getUserFromCtx(r)
+
+	if followUser.IsPrivate && followUser.ID != user.ID {
+		app.requestFollow(w, r, user, followUser)
 		return
 	}
 
-	followUser := getUserFromCtx(r)
-
-	if err := app.store.Followers.Follow(r.Context(), followUser.ID, payload.UserID); err != nil {
+	if err := app.store.Followers.Follow(r.Context(), user.ID, followUser.ID); err != nil {
 		switch err {
 		case store.ErrorConflict:
-			app.conflictError(w, r, err)
+			app.conflictError(w, r, errAlreadyFollowing)
+		case store.ErrBlocked:
+			app.forbiddenError(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
 
+	if err := app.sendNewFollowerEmail(followUser, user); err != nil {
+		app.logger.Errorw("cannot send new follower email", "user_id", followUser.ID, "error", err.Error())
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, nil); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -111,22 +153,18 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
-//	@Param			userId	path		int			true	"User ID"
-//	@Param			payload	body		FollowUser	true	"Unfollow payload"
+//	@Param			userId	path		int	true	"User ID"
 //	@Success		200		{object}	map[string]interface{}
 //	@Failure		400		{string}	string	"Bad request"
+//	@Failure		401		{object}	map[string]string
 //	@Failure		500		{object}	map[string]string
-//	@Router			/users/{userId}/unfollow [post]
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userId}/unfollow [put]
 func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
-	var payload FollowUser
-	if err := readJson(w, r, &payload); err != nil {
-		app.badRequestError(w, r, err)
-		return
-	}
-
+	user := getAuthUserFromCtx(r)
 	unfollowUser := getUserFromCtx(r)
 
-	if err := app.store.Followers.Unfollow(r.Context(), unfollowUser.ID, payload.UserID); err != nil {
+	if err := app.store.Followers.Unfollow(r.Context(), user.ID, unfollowUser.ID); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -155,7 +193,283 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+type ResendActivationPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// ResendActivationHandler godoc
+//
+//	@Summary		Resend the activation email
+//	@Description	Rotates the invitation token of an inactive user and emails a new activation link. Always responds 202 so emails cannot be enumerated
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body	ResendActivationPayload	true	"Account email"
+//	@Success		202
+//	@Failure		400	{object}	map[string]string
+//	@Failure		429	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Router			/users/activate/resend [post]
+func (app *application) resendActivationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResendActivationPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(&payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if allowed, retryAfter := app.activationLimiter.Allow(strings.ToLower(payload.Email)); !allowed {
+		app.rateLimitExceededError(w, r, retryAfter)
+		return
+	}
+
+	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	if err != nil && !errors.Is(err, store.ErrorNotFound) {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if user != nil && !user.IsActive {
+		plainToken, hashToken := generateToken()
+
+		if err := app.store.Users.RotateInvitation(r.Context(), user.ID, hashToken, app.config.mail.exp); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if err := app.sendActivationEmail(user, plainToken); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 func getUserFromCtx(r *http.Request) *store.User {
 	user, _ := r.Context().Value(userCtx).(*store.User)
 	return user
 }
+
+type UpdateUserPayload struct {
+	Username    *string `json:"username" validate:"omitempty,min=1,max=100"`
+	DisplayName *string `json:"display_name" validate:"omitempty,max=100"`
+	Bio         *string `json:"bio" validate:"omitempty,max=500"`
+	Website     *string `json:"website" validate:"omitempty,http_url,max=255"`
+	AvatarURL   *string `json:"avatar_url" validate:"omitempty,http_url,max=2048"`
+	IsPrivate   *bool   `json:"is_private"`
+}
+
+// UpdateMeHandler godoc
+//
+//	@Summary		Update the current user
+//	@Description	Changes the username and profile of the authenticated user. Email changes go through /users/me/email
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateUserPayload	true	"Fields to update"
+//	@Success		200		{object}	store.Profile
+//	@Failure		400		{object}	map[string]string
+//	@Failure		401		{object}	map[string]string
+//	@Failure		409		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *application) updateMeHandler(w http.ResponseWriter, r *http.Request) {
+	user := getAuthUserFromCtx(r)
+
+	var payload UpdateUserPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(&payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if payload.Username != nil {
+		user.UserName = *payload.Username
+	}
+	if payload.DisplayName != nil {
+		user.DisplayName = *payload.DisplayName
+	}
+	if payload.Bio != nil {
+		user.Bio = *payload.Bio
+	}
+	if payload.Website != nil {
+		user.Website = *payload.Website
+	}
+	if payload.AvatarURL != nil {
+		user.AvatarURL = *payload.AvatarURL
+	}
+	if payload.IsPrivate != nil {
+		user.IsPrivate = *payload.IsPrivate
+	}
+
+	if err := app.store.Users.Update(r.Context(), user); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateUsername):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	profile, err := app.getProfile(r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, profile); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type EmailChangePayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// RequestEmailChangeHandler godoc
+//
+//	@Summary		Change the email address
+//	@Description	Emails a confirmation link to the new address. The current address stays active until the change is confirmed
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body	EmailChangePayload	true	"New email"
+//	@Success		202
+//	@Failure		400	{object}	map[string]string
+//	@Failure		401	{object}	map[string]string
+//	@Failure		409	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/email [post]
+func (app *application) requestEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	user := getAuthUserFromCtx(r)
+
+	var payload EmailChangePayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := getValidator().Struct(&payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if strings.EqualFold(payload.Email, user.Email) {
+		app.badRequestError(w, r, errSameEmail)
+		return
+	}
+
+	_, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	switch {
+	case err == nil:
+		app.conflictError(w, r, store.ErrDuplicateEmail)
+		return
+	case !errors.Is(err, store.ErrorNotFound):
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	plainToken, hashToken := generateToken()
+
+	if err := app.store.Users.CreateEmailChange(
+		r.Context(),
+		user.ID,
+		payload.Email,
+		hashToken,
+		app.config.emailChange.exp,
+	); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.sendEmailChangeEmail(user, payload.Email, plainToken); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ConfirmEmailChangeHandler godoc
+//
+//	@Summary		Confirm an email change
+//	@Description	Switches the account to the new address using the token emailed to it. The emailed link opens a frontend page that calls this endpoint once the user confirms
+//	@Tags			users
+//	@Produce		json
+//	@Param			token	path		string	true	"Email change token"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	map[string]string
+//	@Failure		409		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Router			/users/email/confirm/{token} [put]
+func (app *application) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := app.store.Users.ConfirmEmailChange(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrorNotFound):
+			app.badRequestError(w, r, err)
+		case errors.Is(err, store.ErrDuplicateEmail):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type AccountDeletion struct {
+	DeletionScheduledFor time.Time `json:"deletion_scheduled_for"`
+}
+
+// DeleteMeHandler godoc
+//
+//	@Summary		Delete the current user
+//	@Description	Schedules the account for deletion after a grace period and signs out every session. Signing in again before then cancels the deletion
+//	@Tags			users
+//	@Produce		json
+//	@Success		202	{object}	AccountDeletion
+//	@Failure		401	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [delete]
+func (app *application) deleteMeHandler(w http.ResponseWriter, r *http.Request) {
+	user := getAuthUserFromCtx(r)
+
+	scheduledFor, err := app.store.Users.ScheduleDeletion(r.Context(), user.ID, app.config.accountDeletion.grace)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.revokeSessions(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, AccountDeletion{DeletionScheduledFor: scheduledFor}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
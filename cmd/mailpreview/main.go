@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/Dinuka-Dilshan/go-web-dev/internal/mailer"
+)
+
+var sampleData = map[string]any{
+	mailer.ActivationTemplate: mailer.ActivationData{
+		UserName:      "gopher",
+		ActivationURL: "http://localhost:5173/activate/00000000-0000-0000-0000-000000000000",
+		Expiry:        "2006-01-02 15:04 UTC",
+	},
+	mailer.PasswordResetTemplate: mailer.PasswordResetData{
+		UserName: "gopher",
+		ResetURL: "http://localhost:5173/reset-password/00000000-0000-0000-0000-000000000000",
+		Expiry:   "2006-01-02 15:04 UTC",
+	},
+	mailer.NewFollowerTemplate: mailer.NewFollowerData{
+		UserName:     "gopher",
+		FollowerName: "alice",
+	},
+	mailer.MagicLinkTemplate: mailer.MagicLinkData{
+		UserName: "gopher",
+		LoginURL: "http://localhost:5173/magic-link/00000000-0000-0000-0000-000000000000",
+		Expiry:   "2006-01-02 15:04 UTC",
+	},
+	mailer.EmailChangeTemplate: mailer.EmailChangeData{
+		UserName:   "gopher",
+		Email:      "new-gopher@example.com",
+		ConfirmURL: "http://localhost:5173/confirm-email/00000000-0000-0000-0000-000000000000",
+		Expiry:     "2006-01-02 15:04 UTC",
+	},
+}
+
+// Renders an email template with sample data without sending it.
+//
+//	go run ./cmd/mailpreview -template activation -locale es -format html
+func main() {
+	name := flag.String("template", mailer.ActivationTemplate, "template name")
+	locale := flag.String("locale", mailer.DefaultLocale, "recipient locale")
+	format := flag.String("format", "text", "output format: text or html")
+	flag.Parse()
+
+	data, ok := sampleData[*name]
+	if !ok {
+		log.Fatalf("unknown template %q", *name)
+	}
+
+	message, err := mailer.Render(*name, *locale, data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch *format {
+	case "text":
+		fmt.Printf("Subject: %s\n\n%s", message.Subject, message.Text)
+	case "html":
+		fmt.Print(message.HTML)
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS token_revocations (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  jti text,
+  revoked_at timestamp with time zone NOT NULL DEFAULT NOW(),
+  expiry timestamp(0) with time zone NOT NULL,
+
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_token_revocations_jti ON token_revocations (jti);
+CREATE INDEX IF NOT EXISTS idx_token_revocations_user_id ON token_revocations (user_id);
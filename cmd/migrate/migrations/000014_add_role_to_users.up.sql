@@ -0,0 +1,4 @@
+ALTER TABLE
+  users
+ADD
+  COLUMN role varchar(20) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin'));
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE
+  users DROP COLUMN locale;
\ No newline at end of file
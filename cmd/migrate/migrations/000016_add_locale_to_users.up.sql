@@ -0,0 +1,4 @@
+ALTER TABLE
+  users
+ADD
+  COLUMN locale varchar(35) NOT NULL DEFAULT 'en';
\ No newline at end of file
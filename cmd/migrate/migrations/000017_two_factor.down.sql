@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS two_factor_challenges;
+
+DROP TABLE IF EXISTS recovery_codes;
+
+ALTER TABLE
+  users DROP COLUMN totp_enabled,
+  DROP COLUMN totp_secret;
\ No newline at end of file
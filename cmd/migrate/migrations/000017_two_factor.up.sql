@@ -0,0 +1,26 @@
+ALTER TABLE
+  users
+ADD
+  COLUMN totp_secret text,
+ADD
+  COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  code bytea NOT NULL,
+  used_at timestamp(0) with time zone,
+
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS two_factor_challenges (
+  token bytea PRIMARY KEY,
+  user_id bigint NOT NULL,
+  attempts int NOT NULL DEFAULT 0,
+  expiry timestamp(0) with time zone NOT NULL,
+
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
\ No newline at end of file
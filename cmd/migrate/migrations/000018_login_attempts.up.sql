@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+  key text PRIMARY KEY,
+  failures int NOT NULL DEFAULT 0,
+  last_failure_at timestamp with time zone NOT NULL DEFAULT NOW(),
+  locked_until timestamp with time zone
+);
\ No newline at end of file
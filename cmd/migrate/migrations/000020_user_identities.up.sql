@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  provider text NOT NULL,
+  subject text NOT NULL,
+  email citext,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  UNIQUE (provider, subject),
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+  state bytea PRIMARY KEY,
+  code_verifier text NOT NULL,
+  nonce text NOT NULL,
+  expiry timestamp(0) with time zone NOT NULL
+);
\ No newline at end of file
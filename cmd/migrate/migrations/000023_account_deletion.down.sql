@@ -0,0 +1,9 @@
+DELETE FROM comments WHERE user_id IS NULL;
+
+ALTER TABLE
+  comments
+ALTER COLUMN
+  user_id SET NOT NULL;
+
+ALTER TABLE
+  users DROP COLUMN deletion_scheduled_for;
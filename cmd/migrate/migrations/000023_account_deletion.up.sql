@@ -0,0 +1,11 @@
+ALTER TABLE
+  users
+ADD
+  COLUMN deletion_scheduled_for timestamp(0) with time zone;
+
+ALTER TABLE
+  comments
+ALTER COLUMN
+  user_id DROP NOT NULL,
+ALTER COLUMN
+  user_id DROP DEFAULT;
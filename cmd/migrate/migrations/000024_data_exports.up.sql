@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS data_exports (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  status varchar(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  completed_at timestamp(0) with time zone,
+  expiry timestamp(0) with time zone,
+
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_pending ON data_exports (user_id) WHERE status = 'pending';
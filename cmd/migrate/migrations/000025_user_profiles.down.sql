@@ -0,0 +1,5 @@
+ALTER TABLE
+  users DROP COLUMN display_name,
+  DROP COLUMN bio,
+  DROP COLUMN website,
+  DROP COLUMN avatar_url;
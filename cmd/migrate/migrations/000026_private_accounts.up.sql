@@ -0,0 +1,14 @@
+ALTER TABLE
+  users
+ADD
+  COLUMN is_private boolean NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS follow_requests (
+  user_id bigint NOT NULL,
+  follower_id bigint NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY (user_id, follower_id),
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  FOREIGN KEY (follower_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,11 @@
+-- Replies become top level comments so none are lost with the placeholders.
+UPDATE comments SET parent_id = NULL WHERE parent_id IS NOT NULL;
+
+DELETE FROM comments WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_comments_parent_id;
+
+ALTER TABLE
+  comments DROP COLUMN deleted_at,
+  DROP COLUMN depth,
+  DROP COLUMN parent_id;
@@ -0,0 +1,10 @@
+ALTER TABLE
+  comments
+ADD
+  COLUMN parent_id bigint REFERENCES comments (id) ON DELETE NO ACTION,
+ADD
+  COLUMN depth int NOT NULL DEFAULT 0,
+ADD
+  COLUMN deleted_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments (parent_id, created_at, id);
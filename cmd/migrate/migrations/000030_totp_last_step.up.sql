@@ -0,0 +1,4 @@
+ALTER TABLE
+  users
+ADD
+  COLUMN totp_last_step bigint;
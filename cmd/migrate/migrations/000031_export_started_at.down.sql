@@ -0,0 +1,2 @@
+ALTER TABLE
+  data_exports DROP COLUMN started_at;
@@ -0,0 +1,4 @@
+ALTER TABLE
+  data_exports
+ADD
+  COLUMN started_at timestamp(0) with time zone;
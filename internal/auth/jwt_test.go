@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWTAuthenticatorKeepsSubSecondIssueTimes(t *testing.T) {
+	authenticator := NewJWTAuthenticator("test-secret", "test-aud", "test-iss")
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+	}{
+		{"whole second", time.Unix(1700000000, 0)},
+		{"milliseconds", time.Unix(1700000000, 250_000_000)},
+		{"microseconds", time.Unix(1700000000, 999_999_000)},
+		{"nanoseconds are dropped", time.Unix(1700000000, 123_456_789)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := authenticator.GenerateToken(jwt.RegisteredClaims{
+				Audience:  jwt.ClaimStrings{"test-aud"},
+				Issuer:    "test-iss",
+				IssuedAt:  jwt.NewNumericDate(test.issuedAt),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := authenticator.ValidateToken(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			issuedAt, err := parsed.Claims.GetIssuedAt()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Parsing goes through a float, which can lose the last microsecond.
+			want := test.issuedAt.Truncate(time.Microsecond)
+			if diff := want.Sub(issuedAt.Time); diff < 0 || diff > time.Microsecond {
+				t.Errorf("issued at = %v, want %v", issuedAt.Time, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var ErrInvalidNonce = errors.New("id token nonce does not match")
+
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// OIDCIdentity is the subset of ID token claims used to link an account.
+type OIDCIdentity struct {
+	Issuer        string `json:"-"`
+	Subject       string `json:"-"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// OIDCProvider runs the authorization code flow with PKCE against a single
+// OpenID Connect provider.
+type OIDCProvider struct {
+	issuer   string
+	oauth    oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func NewOIDCProvider(ctx context.Context, config OIDCConfig) (*OIDCProvider, error) {
+	provider, err := oidc.NewProvider(ctx, config.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("cannot discover oidc provider: %w", err)
+	}
+
+	return &OIDCProvider{
+		issuer: config.Issuer,
+		oauth: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: config.ClientID}),
+	}, nil
+}
+
+func (provider *OIDCProvider) Issuer() string {
+	return provider.issuer
+}
+
+// AuthCodeURL returns the provider URL the user is sent to. verifier is the
+// PKCE code verifier that must be presented again in Exchange.
+func (provider *OIDCProvider) AuthCodeURL(state string, nonce string, verifier string) string {
+	return provider.oauth.AuthCodeURL(
+		state,
+		oidc.Nonce(nonce),
+		oauth2.S256ChallengeOption(verifier),
+	)
+}
+
+// Exchange trades an authorization code for tokens and returns the verified identity.
+func (provider *OIDCProvider) Exchange(
+	ctx context.Context,
+	code string,
+	nonce string,
+	verifier string,
+) (*OIDCIdentity, error) {
+	token, err := provider.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("cannot exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	idToken, err := provider.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("cannot verify id token: %w", err)
+	}
+
+	if idToken.Nonce != nonce {
+		return nil, ErrInvalidNonce
+	}
+
+	identity := OIDCIdentity{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+	}
+
+	if err := idToken.Claims(&identity); err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+// GenerateCodeVerifier returns a random PKCE code verifier.
+func GenerateCodeVerifier() string {
+	return oauth2.GenerateVerifier()
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Usernames and email addresses shorter than this are not looked for in
+// passwords, as they would match too often to be meaningful.
+const minIdentityLength = 3
+
+// PasswordPolicy describes the rules a new password has to satisfy.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// RejectIdentity rejects passwords that contain the username or the
+	// local part of the email.
+	RejectIdentity bool
+}
+
+// Check returns a message for every rule password breaks. username and email
+// are optional and only used when RejectIdentity is set.
+func (policy PasswordPolicy) Check(password string, username string, email string) []string {
+	var violations []string
+
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", policy.MinLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r), unicode.IsSymbol(r), unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	if policy.RequireUpper && !upper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if policy.RequireLower && !lower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if policy.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if policy.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	if policy.RejectIdentity {
+		lowered := strings.ToLower(password)
+		local, _, _ := strings.Cut(email, "@")
+
+		if len(username) >= minIdentityLength && strings.Contains(lowered, strings.ToLower(username)) {
+			violations = append(violations, "must not contain the username")
+		}
+		if len(local) >= minIdentityLength && strings.Contains(lowered, strings.ToLower(local)) {
+			violations = append(violations, "must not contain the email address")
+		}
+	}
+
+	return violations
+}
+
+// BreachedPasswords checks passwords against a local copy of a breached
+// password corpus laid out for k-anonymity lookups: dir holds one file per
+// five character SHA-1 prefix, named "<PREFIX>.txt", with a "<SUFFIX>:<COUNT>"
+// line for every breached hash in that range. This is the format served by
+// the Pwned Passwords range API, so a downloaded copy can be used as is.
+type BreachedPasswords struct {
+	dir string
+}
+
+func NewBreachedPasswords(dir string) (*BreachedPasswords, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open breached password directory: %w", err)
+	}
+
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached password path %s is not a directory", dir)
+	}
+
+	return &BreachedPasswords{dir: dir}, nil
+}
+
+// IsBreached reports whether password appears in the corpus. Only the range
+// file for the hash prefix is read.
+func (breached *BreachedPasswords) IsBreached(password string) (bool, error) {
+	hash := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(hash[:]))
+	prefix, suffix := digest[:5], digest[5:]
+
+	file, err := os.Open(filepath.Join(breached.dir, prefix+".txt"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(line, suffix) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	strict := PasswordPolicy{
+		MinLength:      10,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireDigit:   true,
+		RequireSymbol:  true,
+		RejectIdentity: true,
+	}
+
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		username string
+		email    string
+		want     []string
+	}{
+		{
+			name:     "satisfies every rule",
+			policy:   strict,
+			password: "Correct-Horse-7",
+		},
+		{
+			name:     "empty policy accepts anything",
+			password: "",
+		},
+		{
+			name:     "one character short",
+			policy:   PasswordPolicy{MinLength: 10},
+			password: "123456789",
+			want:     []string{"must be at least 10 characters long"},
+		},
+		{
+			name:     "exactly the minimum length",
+			policy:   PasswordPolicy{MinLength: 10},
+			password: "1234567890",
+		},
+		{
+			name:     "length counts characters rather than bytes",
+			policy:   PasswordPolicy{MinLength: 4},
+			password: "ñöçü",
+		},
+		{
+			name:     "missing every class",
+			policy:   strict,
+			password: "          ",
+			want: []string{
+				"must contain an uppercase letter",
+				"must contain a lowercase letter",
+				"must contain a digit",
+			},
+		},
+		{
+			name:     "missing the symbol",
+			policy:   strict,
+			password: "CorrectHorse7",
+			want:     []string{"must contain a symbol"},
+		},
+		{
+			name:     "non ascii letters count",
+			policy:   PasswordPolicy{RequireUpper: true, RequireLower: true},
+			password: "ÑÖçü",
+		},
+		{
+			name:     "contains the username in another case",
+			policy:   strict,
+			password: "My-GOPHER-pass-1",
+			username: "gopher",
+			email:    "someone@example.com",
+			want:     []string{"must not contain the username"},
+		},
+		{
+			name:     "contains the email local part",
+			policy:   strict,
+			password: "Hello-Alice.Smith-1",
+			username: "gopher",
+			email:    "alice.smith@example.com",
+			want:     []string{"must not contain the email address"},
+		},
+		{
+			name:     "contains the email domain only",
+			policy:   strict,
+			password: "Example.com-Pass-1",
+			email:    "alice@example.com",
+		},
+		{
+			name:     "short identities are ignored",
+			policy:   strict,
+			password: "Abc-Defgh-12",
+			username: "ab",
+			email:    "de@example.com",
+		},
+		{
+			name:     "identity checks are off",
+			policy:   PasswordPolicy{MinLength: 1},
+			password: "gopher",
+			username: "gopher",
+			email:    "gopher@example.com",
+		},
+		{
+			name:     "identity checks without identity",
+			policy:   strict,
+			password: "Correct-Horse-7",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.policy.Check(test.password, test.username, test.email)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("Check() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	digest := func(password string) (string, string) {
+		hash := sha1.Sum([]byte(password))
+		hexDigest := strings.ToUpper(hex.EncodeToString(hash[:]))
+		return hexDigest[:5], hexDigest[5:]
+	}
+
+	dir := t.TempDir()
+
+	prefix, suffix := digest("password123")
+	lowerPrefix, lowerSuffix := digest("hunter2")
+	otherPrefix, _ := digest("not in the corpus")
+
+	files := map[string]string{
+		// Ranges list other hashes with the same prefix and may use CRLF.
+		prefix: "0000000000000000000000000000000000A:3\r\n" + suffix + ":1337\r\n",
+		// Suffixes are compared without regard to case.
+		lowerPrefix: strings.ToLower(lowerSuffix) + ":12\n",
+		otherPrefix: "0000000000000000000000000000000000A:3\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name+".txt"), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	breached, err := NewBreachedPasswords(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{"listed", "password123", true},
+		{"listed in lower case", "hunter2", true},
+		{"prefix file without the suffix", "not in the corpus", false},
+		{"no range file", "Correct-Horse-7", false},
+		{"case matters for the password", "Password123", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := breached.IsBreached(test.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != test.want {
+				t.Errorf("IsBreached(%q) = %v, want %v", test.password, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewBreachedPasswords(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "corpus.txt")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		dir     string
+		wantErr bool
+	}{
+		{"directory", t.TempDir(), false},
+		{"missing", filepath.Join(t.TempDir(), "missing"), true},
+		{"file", file, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewBreachedPasswords(test.dir); (err != nil) != test.wantErr {
+				t.Errorf("NewBreachedPasswords() error = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package auth
+
+import "github.com/Dinuka-Dilshan/go-web-dev/internal/store"
+
+// PostPolicy decides whether a user may act on a post.
+type PostPolicy func(user *store.User, post *store.Post) bool
+
+var (
+	CanUpdatePost PostPolicy = ownerOrRole(store.RoleModerator)
+	CanDeletePost PostPolicy = ownerOrRole(store.RoleAdmin)
+)
+
+// ownerOrRole lets the post owner through, as well as privileged users.
+// Privileges are only honoured once the account has two-factor enabled.
+func ownerOrRole(role string) PostPolicy {
+	return func(user *store.User, post *store.Post) bool {
+		return user.ID == post.UserId || (user.HasRole(role) && user.TwoFactorEnabled)
+	}
+}
+
+// CommentPolicy decides whether a user may act on a comment on post.
+type CommentPolicy func(user *store.User, post *store.Post, comment *store.Comment) bool
+
+// CanUpdateComment only lets the author edit a comment.
+func CanUpdateComment(user *store.User, post *store.Post, comment *store.Comment) bool {
+	return user.ID == comment.UserId
+}
+
+// CanDeleteComment lets the author of a comment, or the owner of the post it
+// was left on, delete it.
+func CanDeleteComment(user *store.User, post *store.Post, comment *store.Comment) bool {
+	return user.ID == comment.UserId || user.ID == post.UserId
+}
+
+// CanViewEmail lets users see their own email address. Admins can see anyone's,
+// as long as they have two-factor enabled.
+func CanViewEmail(viewer *store.User, user *store.User) bool {
+	return viewer.ID == user.ID || (viewer.HasRole(store.RoleAdmin) && viewer.TwoFactorEnabled)
+}
+
+// CanViewPosts decides whether viewer may see the posts of author. Private
+// accounts only share posts with their followers and with moderators.
+func CanViewPosts(viewer *store.User, author *store.User, following bool) bool {
+	if !author.IsPrivate || viewer.ID == author.ID || following {
+		return true
+	}
+
+	return viewer.HasRole(store.RoleModerator) && viewer.TwoFactorEnabled
+}
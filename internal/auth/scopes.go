@@ -0,0 +1,24 @@
+package auth
+
+import "slices"
+
+// Scopes that can be granted to API keys.
+const (
+	ScopePostsRead    = "posts:read"
+	ScopePostsWrite   = "posts:write"
+	ScopeFeedRead     = "feed:read"
+	ScopeUsersRead    = "users:read"
+	ScopeFollowsWrite = "follows:write"
+)
+
+var Scopes = []string{
+	ScopePostsRead,
+	ScopePostsWrite,
+	ScopeFeedRead,
+	ScopeUsersRead,
+	ScopeFollowsWrite,
+}
+
+func IsValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
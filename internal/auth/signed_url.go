@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("url signature is invalid")
+	ErrSignatureExpired = errors.New("url signature has expired")
+)
+
+// URLSigner issues links that grant access to a path until they expire,
+// without the caller having to authenticate.
+type URLSigner struct {
+	secret []byte
+}
+
+func NewURLSigner(secret string) *URLSigner {
+	return &URLSigner{secret: []byte(secret)}
+}
+
+// Sign returns the query parameters that authorize path until expiry.
+func (signer *URLSigner) Sign(path string, expiry time.Time) url.Values {
+	expires := strconv.FormatInt(expiry.Unix(), 10)
+
+	return url.Values{
+		"expires":   {expires},
+		"signature": {signer.signature(path, expires)},
+	}
+}
+
+func (signer *URLSigner) Verify(path string, query url.Values) error {
+	expires := query.Get("expires")
+
+	signature, err := hex.DecodeString(query.Get("signature"))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	expected, _ := hex.DecodeString(signer.signature(path, expires))
+	if !hmac.Equal(signature, expected) {
+		return ErrInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if time.Now().After(time.Unix(unix, 0)) {
+		return ErrSignatureExpired
+	}
+
+	return nil
+}
+
+func (signer *URLSigner) signature(path string, expires string) string {
+	mac := hmac.New(sha256.New, signer.secret)
+	mac.Write([]byte(path + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
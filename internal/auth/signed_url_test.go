@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestURLSignerVerify(t *testing.T) {
+	signer := NewURLSigner("test-signing-secret")
+	path := "/v1/exports/1/download"
+
+	signed := func(expiry time.Time) url.Values {
+		return signer.Sign(path, expiry)
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		query func() url.Values
+		want  error
+	}{
+		{
+			"valid",
+			path,
+			func() url.Values { return signed(time.Now().Add(time.Hour)) },
+			nil,
+		},
+		{
+			"expired",
+			path,
+			func() url.Values { return signed(time.Now().Add(-time.Minute)) },
+			ErrSignatureExpired,
+		},
+		{
+			"other path",
+			"/v1/exports/2/download",
+			func() url.Values { return signed(time.Now().Add(time.Hour)) },
+			ErrInvalidSignature,
+		},
+		{
+			"extended expiry",
+			path,
+			func() url.Values {
+				query := signed(time.Now().Add(-time.Minute))
+				query.Set("expires", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+				return query
+			},
+			ErrInvalidSignature,
+		},
+		{
+			"tampered signature",
+			path,
+			func() url.Values {
+				query := signed(time.Now().Add(time.Hour))
+				signature := []byte(query.Get("signature"))
+				if signature[0] == '0' {
+					signature[0] = '1'
+				} else {
+					signature[0] = '0'
+				}
+				query.Set("signature", string(signature))
+				return query
+			},
+			ErrInvalidSignature,
+		},
+		{
+			"signature not hex",
+			path,
+			func() url.Values {
+				query := signed(time.Now().Add(time.Hour))
+				query.Set("signature", "not-hex")
+				return query
+			},
+			ErrInvalidSignature,
+		},
+		{
+			"missing expires",
+			path,
+			func() url.Values {
+				query := signed(time.Now().Add(time.Hour))
+				query.Del("expires")
+				return query
+			},
+			ErrInvalidSignature,
+		},
+		{
+			"missing signature",
+			path,
+			func() url.Values {
+				query := signed(time.Now().Add(time.Hour))
+				query.Del("signature")
+				return query
+			},
+			ErrInvalidSignature,
+		},
+		{
+			"other secret",
+			path,
+			func() url.Values {
+				return NewURLSigner("other-secret").Sign(path, time.Now().Add(time.Hour))
+			},
+			ErrInvalidSignature,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := signer.Verify(test.path, test.query()); !errors.Is(err, test.want) {
+				t.Errorf("Verify() error = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes every message to its own file in dir instead of sending
+// it, which is handy for local development without an SMTP server.
+type FileMailer struct {
+	dir string
+}
+
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create mail directory: %w", err)
+	}
+
+	return &FileMailer{dir}, nil
+}
+
+func (mailer *FileMailer) Send(message *Message) error {
+	if message.To == "" {
+		return ErrMissingRecipient
+	}
+
+	// Valid addresses can contain path separators, so the recipient is
+	// escaped to keep every message directly in dir.
+	name := fmt.Sprintf("%d_%s", time.Now().UnixNano(), url.PathEscape(message.To))
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", message.To, message.Subject, message.Text)
+
+	if err := os.WriteFile(filepath.Join(mailer.dir, name+".txt"), []byte(content), 0o644); err != nil {
+		return err
+	}
+
+	if message.HTML == "" {
+		return nil
+	}
+
+	return os.WriteFile(filepath.Join(mailer.dir, name+".html"), []byte(message.HTML), 0o644)
+}
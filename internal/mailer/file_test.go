@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFileMailerSend(t *testing.T) {
+	tests := []struct {
+		name string
+		to   string
+	}{
+		{"plain address", "gopher@example.com"},
+		{"slash in local part", "a/b@example.com"},
+		{"parent directory", "../../etc/passwd@example.com"},
+		{"backslash", `a\b@example.com`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			mailer, err := NewFileMailer(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := mailer.Send(&Message{To: test.to, Subject: "Hi", Text: "text", HTML: "<p>html</p>"}); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(entries) != 2 {
+				t.Fatalf("wrote %d entries, want a text and an html file", len(entries))
+			}
+
+			for _, entry := range entries {
+				if entry.IsDir() || strings.ContainsAny(entry.Name(), `/\`) {
+					t.Errorf("wrote %q outside the mail directory", entry.Name())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package mailer
+
+import "errors"
+
+var ErrMissingRecipient = errors.New("mail message has no recipient")
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type Mailer interface {
+	Send(message *Message) error
+}
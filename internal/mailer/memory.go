@@ -0,0 +1,37 @@
+package mailer
+
+import "sync"
+
+// InMemoryMailer keeps every message it is asked to send. It is meant for
+// tests that need to assert on outgoing mail.
+type InMemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewInMemoryMailer() *InMemoryMailer {
+	return &InMemoryMailer{}
+}
+
+func (mailer *InMemoryMailer) Send(message *Message) error {
+	if message.To == "" {
+		return ErrMissingRecipient
+	}
+
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+
+	mailer.messages = append(mailer.messages, *message)
+
+	return nil
+}
+
+func (mailer *InMemoryMailer) Messages() []Message {
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+
+	messages := make([]Message, len(mailer.messages))
+	copy(messages, mailer.messages)
+
+	return messages
+}
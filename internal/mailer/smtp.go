@@ -0,0 +1,87 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+type SMTPMailer struct {
+	config SMTPConfig
+}
+
+func NewSMTPMailer(config SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{config}
+}
+
+func (mailer *SMTPMailer) Send(message *Message) error {
+	if message.To == "" {
+		return ErrMissingRecipient
+	}
+
+	var auth smtp.Auth
+	if mailer.config.Username != "" {
+		auth = smtp.PlainAuth("", mailer.config.Username, mailer.config.Password, mailer.config.Host)
+	}
+
+	address := net.JoinHostPort(mailer.config.Host, strconv.Itoa(mailer.config.Port))
+
+	if err := smtp.SendMail(address, auth, mailer.config.From, []string{message.To}, mailer.build(message)); err != nil {
+		return fmt.Errorf("cannot send mail: %w", err)
+	}
+
+	return nil
+}
+
+func (mailer *SMTPMailer) build(message *Message) []byte {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "From: %s\r\n", mailer.config.From)
+	fmt.Fprintf(&builder, "To: %s\r\n", message.To)
+	fmt.Fprintf(&builder, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	builder.WriteString("MIME-Version: 1.0\r\n")
+
+	if message.HTML == "" {
+		builder.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n")
+		builder.WriteString("\r\n")
+		builder.WriteString(message.Text)
+
+		return []byte(builder.String())
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	fmt.Fprintf(&builder, "Content-Type: multipart/alternative; boundary=%q\r\n", writer.Boundary())
+	builder.WriteString("\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=\"UTF-8\"", message.Text},
+		{"text/html; charset=\"UTF-8\"", message.HTML},
+	} {
+		partWriter, _ := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		partWriter.Write([]byte(part.content))
+	}
+	writer.Close()
+
+	builder.Write(body.Bytes())
+
+	return []byte(builder.String())
+}
@@ -0,0 +1,113 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+)
+
+const (
+	ActivationTemplate    = "activation"
+	PasswordResetTemplate = "password_reset"
+	NewFollowerTemplate   = "new_follower"
+	MagicLinkTemplate     = "magic_link"
+	EmailChangeTemplate   = "email_change"
+
+	DefaultLocale = "en"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// Render builds a message from the text and HTML templates of name in the
+// given locale. It falls back to the base language and then to English when
+// the locale has no translation.
+func Render(name string, locale string, data any) (*Message, error) {
+	locale = resolveLocale(name, locale)
+
+	textTmpl, err := texttemplate.ParseFS(templates, templatePath(locale, name, "txt"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s text template: %w", name, err)
+	}
+
+	htmlTmpl, err := htmltemplate.ParseFS(templates, templatePath(locale, name, "html"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s html template: %w", name, err)
+	}
+
+	var subject, text, html bytes.Buffer
+
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+
+	if err := textTmpl.ExecuteTemplate(&text, "body", data); err != nil {
+		return nil, err
+	}
+
+	if err := htmlTmpl.Execute(&html, data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+func resolveLocale(name string, locale string) string {
+	candidates := []string{locale}
+	if base, _, found := strings.Cut(locale, "-"); found {
+		candidates = append(candidates, base)
+	}
+
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		if _, err := fs.Stat(templates, templatePath(candidate, name, "txt")); err == nil {
+			return candidate
+		}
+	}
+
+	return DefaultLocale
+}
+
+func templatePath(locale string, name string, format string) string {
+	return fmt.Sprintf("templates/%s/%s.%s.tmpl", locale, name, format)
+}
+
+type ActivationData struct {
+	UserName      string
+	ActivationURL string
+	Expiry        string
+}
+
+type PasswordResetData struct {
+	UserName string
+	ResetURL string
+	Expiry   string
+}
+
+type NewFollowerData struct {
+	UserName     string
+	FollowerName string
+}
+
+type MagicLinkData struct {
+	UserName string
+	LoginURL string
+	Expiry   string
+}
+
+type EmailChangeData struct {
+	UserName   string
+	Email      string
+	ConfirmURL string
+	Expiry     string
+}
@@ -0,0 +1,67 @@
+package ratelimiter
+
+import (
+	"sync"
+	"time"
+)
+
+type window struct {
+	start time.Time
+	count int
+}
+
+type FixedWindowLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	windows   map[string]*window
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewFixedWindowLimiter(limit int, windowSize time.Duration) *FixedWindowLimiter {
+	return &FixedWindowLimiter{
+		limit:   limit,
+		window:  windowSize,
+		windows: make(map[string]*window),
+		now:     time.Now,
+	}
+}
+
+func (limiter *FixedWindowLimiter) Allow(key string) (bool, time.Duration) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := limiter.now()
+
+	current, ok := limiter.windows[key]
+	if !ok || now.Sub(current.start) >= limiter.window {
+		limiter.evictExpired(now)
+
+		limiter.windows[key] = &window{start: now, count: 1}
+		return true, 0
+	}
+
+	if current.count >= limiter.limit {
+		return false, current.start.Add(limiter.window).Sub(now)
+	}
+
+	current.count++
+
+	return true, 0
+}
+
+// evictExpired drops finished windows at most once per window size so the
+// map does not grow with every key ever seen.
+func (limiter *FixedWindowLimiter) evictExpired(now time.Time) {
+	if now.Sub(limiter.lastSweep) < limiter.window {
+		return
+	}
+	limiter.lastSweep = now
+
+	for key, current := range limiter.windows {
+		if now.Sub(current.start) >= limiter.window {
+			delete(limiter.windows, key)
+		}
+	}
+}
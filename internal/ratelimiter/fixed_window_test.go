@@ -0,0 +1,103 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFixedWindowLimiterAllow(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	type request struct {
+		after     time.Duration
+		key       string
+		allowed   bool
+		remaining time.Duration
+	}
+
+	tests := []struct {
+		name     string
+		limit    int
+		requests []request
+	}{
+		{
+			name:  "up to the limit",
+			limit: 3,
+			requests: []request{
+				{0, "a", true, 0},
+				{time.Second, "a", true, 0},
+				{2 * time.Second, "a", true, 0},
+				{3 * time.Second, "a", false, 57 * time.Second},
+				{59 * time.Second, "a", false, time.Second},
+			},
+		},
+		{
+			name:  "a new window starts once the old one ends",
+			limit: 1,
+			requests: []request{
+				{0, "a", true, 0},
+				{time.Minute - time.Nanosecond, "a", false, time.Nanosecond},
+				{time.Minute, "a", true, 0},
+				{time.Minute + time.Second, "a", false, 59 * time.Second},
+			},
+		},
+		{
+			name:  "keys are limited separately",
+			limit: 1,
+			requests: []request{
+				{0, "a", true, 0},
+				{0, "b", true, 0},
+				{time.Second, "a", false, 59 * time.Second},
+				{time.Second, "b", false, 59 * time.Second},
+			},
+		},
+		{
+			name:  "rejected requests do not extend the window",
+			limit: 1,
+			requests: []request{
+				{0, "a", true, 0},
+				{30 * time.Second, "a", false, 30 * time.Second},
+				{45 * time.Second, "a", false, 15 * time.Second},
+				{time.Minute, "a", true, 0},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter := NewFixedWindowLimiter(test.limit, time.Minute)
+
+			for i, request := range test.requests {
+				limiter.now = func() time.Time { return start.Add(request.after) }
+
+				allowed, remaining := limiter.Allow(request.key)
+				if allowed != request.allowed || remaining != request.remaining {
+					t.Errorf("request %d: Allow(%q) = %v, %v, want %v, %v",
+						i, request.key, allowed, remaining, request.allowed, request.remaining)
+				}
+			}
+		})
+	}
+}
+
+func TestFixedWindowLimiterEvictsExpiredWindows(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	now := start
+
+	limiter := NewFixedWindowLimiter(1, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	limiter.Allow("a")
+	limiter.Allow("b")
+
+	now = start.Add(time.Minute)
+	limiter.Allow("c")
+
+	if _, found := limiter.windows["a"]; found {
+		t.Error("expired window of a was kept")
+	}
+
+	if len(limiter.windows) != 1 {
+		t.Errorf("kept %d windows, want only c", len(limiter.windows))
+	}
+}
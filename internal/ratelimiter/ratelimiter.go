@@ -0,0 +1,9 @@
+package ratelimiter
+
+import "time"
+
+type Limiter interface {
+	// Allow reports whether another request for key fits in the limit and,
+	// when it does not, how long the caller should wait before retrying.
+	Allow(key string) (bool, time.Duration)
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserId     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+type APIKeyStore struct {
+	db *pgxpool.Pool
+}
+
+func (apiKeyStore *APIKeyStore) Create(ctx context.Context, apiKey *APIKey, key string) error {
+	query := `INSERT INTO api_keys (user_id, name, prefix, key, scopes)
+			  VALUES ($1,$2,$3,$4,$5)
+			  RETURNING id, created_at`
+
+	return apiKeyStore.db.QueryRow(
+		ctx,
+		query,
+		apiKey.UserId,
+		apiKey.Name,
+		apiKey.Prefix,
+		key,
+		apiKey.Scopes,
+	).Scan(&apiKey.ID, &apiKey.CreatedAt)
+}
+
+func (apiKeyStore *APIKeyStore) GetByUser(ctx context.Context, userId int) ([]*APIKey, error) {
+	query := `SELECT id, user_id, name, prefix, scopes, created_at, last_used_at
+			  FROM api_keys
+			  WHERE user_id = $1 AND revoked_at IS NULL
+			  ORDER BY created_at DESC`
+
+	rows, err := apiKeyStore.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*APIKey, error) {
+		var apiKey APIKey
+		if err := row.Scan(
+			&apiKey.ID,
+			&apiKey.UserId,
+			&apiKey.Name,
+			&apiKey.Prefix,
+			&apiKey.Scopes,
+			&apiKey.CreatedAt,
+			&apiKey.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		return &apiKey, nil
+	})
+}
+
+// Authenticate looks up an active key by its hash and records that it was used.
+func (apiKeyStore *APIKeyStore) Authenticate(ctx context.Context, key string) (*APIKey, error) {
+	query := `UPDATE api_keys SET last_used_at = NOW()
+			  WHERE key = $1 AND revoked_at IS NULL
+			  RETURNING id, user_id, name, prefix, scopes, created_at, last_used_at`
+
+	var apiKey APIKey
+	err := apiKeyStore.db.QueryRow(ctx, query, key).Scan(
+		&apiKey.ID,
+		&apiKey.UserId,
+		&apiKey.Name,
+		&apiKey.Prefix,
+		&apiKey.Scopes,
+		&apiKey.CreatedAt,
+		&apiKey.LastUsedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &apiKey, nil
+}
+
+func (apiKeyStore *APIKeyStore) Revoke(ctx context.Context, userId int, keyId int) error {
+	query := `UPDATE api_keys SET revoked_at = NOW()
+			  WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	cmd, err := apiKeyStore.db.Exec(ctx, query, keyId, userId)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+// RevokeAllForUser revokes every active API key of the user.
+func (apiKeyStore *APIKeyStore) RevokeAllForUser(ctx context.Context, userId int) error {
+	_, err := apiKeyStore.db.Exec(ctx, `UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userId)
+
+	return err
+}
+
+// Export returns the user's API keys, including revoked ones.
+func (apiKeyStore *APIKeyStore) Export(ctx context.Context, userId int) (any, error) {
+	query := `SELECT id, user_id, name, prefix, scopes, created_at, last_used_at
+			  FROM api_keys
+			  WHERE user_id = $1
+			  ORDER BY created_at`
+
+	rows, err := apiKeyStore.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*APIKey, error) {
+		var apiKey APIKey
+		if err := row.Scan(
+			&apiKey.ID,
+			&apiKey.UserId,
+			&apiKey.Name,
+			&apiKey.Prefix,
+			&apiKey.Scopes,
+			&apiKey.CreatedAt,
+			&apiKey.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		return &apiKey, nil
+	})
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrBlocked = errors.New("one of the users has blocked the other")
+
+// blockedBetween is a SQL condition that holds when either of the users in
+// the columns or parameters a and b has blocked the other.
+func blockedBetween(a string, b string) string {
+	return `EXISTS (SELECT 1 FROM user_blocks ub
+			WHERE (ub.user_id = ` + a + ` AND ub.blocked_id = ` + b + `)
+			OR (ub.user_id = ` + b + ` AND ub.blocked_id = ` + a + `))`
+}
+
+func isBlocked(ctx context.Context, db *pgxpool.Pool, userId int, otherId int) (bool, error) {
+	var blocked bool
+	err := db.QueryRow(ctx, `SELECT `+blockedBetween("$1", "$2"), userId, otherId).Scan(&blocked)
+
+	return blocked, err
+}
+
+type BlockedUser struct {
+	UserId    int       `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type BlockStore struct {
+	db *pgxpool.Pool
+}
+
+// Block stops userId and blockedId from seeing each other's content and
+// removes the follows and follow requests between them.
+func (blockStore *BlockStore) Block(ctx context.Context, userId int, blockedId int) error {
+	return withTransaction(blockStore.db, ctx, func(tx pgx.Tx) error {
+		queries := []string{
+			`INSERT INTO user_blocks (user_id, blocked_id) VALUES ($1,$2) ON CONFLICT DO NOTHING`,
+			`DELETE FROM followers
+			 WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)`,
+			`DELETE FROM follow_requests
+			 WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)`,
+		}
+
+		for _, query := range queries {
+			if _, err := tx.Exec(ctx, query, userId, blockedId); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (blockStore *BlockStore) Unblock(ctx context.Context, userId int, blockedId int) error {
+	_, err := blockStore.db.Exec(ctx, `DELETE FROM user_blocks WHERE user_id = $1 AND blocked_id = $2`, userId, blockedId)
+
+	return err
+}
+
+// IsBlocked reports whether either user has blocked the other.
+func (blockStore *BlockStore) IsBlocked(ctx context.Context, userId int, otherId int) (bool, error) {
+	return isBlocked(ctx, blockStore.db, userId, otherId)
+}
+
+// Mute hides the posts of mutedId from the feed of userId.
+func (blockStore *BlockStore) Mute(ctx context.Context, userId int, mutedId int) error {
+	query := `INSERT INTO user_mutes (user_id, muted_id) VALUES ($1,$2) ON CONFLICT DO NOTHING`
+
+	_, err := blockStore.db.Exec(ctx, query, userId, mutedId)
+
+	return err
+}
+
+func (blockStore *BlockStore) Unmute(ctx context.Context, userId int, mutedId int) error {
+	_, err := blockStore.db.Exec(ctx, `DELETE FROM user_mutes WHERE user_id = $1 AND muted_id = $2`, userId, mutedId)
+
+	return err
+}
+
+// BlockExport lists the users someone has blocked or muted.
+type BlockExport struct {
+	Blocked []BlockedUser `json:"blocked"`
+	Muted   []BlockedUser `json:"muted"`
+}
+
+// Export returns the users blocked and muted by the user.
+func (blockStore *BlockStore) Export(ctx context.Context, userId int) (any, error) {
+	var export BlockExport
+	var err error
+
+	export.Blocked, err = blockStore.exportUsers(ctx, `SELECT blocked_id, created_at FROM user_blocks WHERE user_id = $1`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	export.Muted, err = blockStore.exportUsers(ctx, `SELECT muted_id, created_at FROM user_mutes WHERE user_id = $1`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+func (blockStore *BlockStore) exportUsers(ctx context.Context, query string, userId int) ([]BlockedUser, error) {
+	rows, err := blockStore.db.Query(ctx, query+` ORDER BY created_at`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (BlockedUser, error) {
+		var user BlockedUser
+		err := row.Scan(&user.UserId, &user.CreatedAt)
+		return user, err
+	})
+}
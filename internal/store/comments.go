@@ -2,60 +2,280 @@ package store
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// DeletedUserName stands in for the author of comments whose account was deleted.
+const DeletedUserName = "[deleted]"
+
+// DeletedCommentContent stands in for the content of deleted comments that
+// are kept because they have replies.
+const DeletedCommentContent = "[deleted]"
+
+// commentColumns are the columns read by scanComment, from comments c joined
+// with users. Comments of deleted accounts, and deleted comments, have user id 0.
+const commentColumns = `c.id, c.post_id, c.parent_id, c.depth, COALESCE(c.user_id, 0),
+			  CASE WHEN c.deleted_at IS NULL THEN c.content ELSE '` + DeletedCommentContent + `' END,
+			  c.created_at, c.updated_at, COALESCE(users.username, '` + DeletedUserName + `'),
+			  c.deleted_at IS NOT NULL,
+			  (SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id)`
+
 type Comment struct {
-	ID        int       `json:"id"`
-	Content   string    `json:"content"`
-	PostId    int       `json:"post_id"`
-	UserId    int       `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UserName  string    `json:"user_name"`
+	ID         int        `json:"id"`
+	Content    string     `json:"content"`
+	PostId     int        `json:"post_id"`
+	ParentId   *int       `json:"parent_id"`
+	UserId     int        `json:"user_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at"`
+	UserName   string     `json:"user_name"`
+	Depth      int        `json:"depth"`
+	Path       []int      `json:"path,omitempty"`
+	ReplyCount int        `json:"reply_count"`
+	Deleted    bool       `json:"deleted"`
+	Replies    []*Comment `json:"replies,omitempty"`
+	// RepliesCursor continues the replies after the last one loaded when
+	// there are more. A comment with a reply count but no replies loaded
+	// has its replies paged from the start.
+	RepliesCursor string `json:"replies_cursor,omitempty"`
 }
 
 type CommentStore struct {
 	db *pgxpool.Pool
 }
 
-func (commentStore *CommentStore) GetByPostId(ctx context.Context, postId int) (*[]Comment, error) {
-	query := `SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username FROM comments c
-			  JOIN users ON users.id = c.user_id
-  			  WHERE c.post_id = $1`
+// scanComment reads commentColumns, followed by any extra columns into extra.
+func scanComment(row pgx.Row, extra ...any) (*Comment, error) {
+	var comment Comment
+	dest := []any{
+		&comment.ID,
+		&comment.PostId,
+		&comment.ParentId,
+		&comment.Depth,
+		&comment.UserId,
+		&comment.Content,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+		&comment.UserName,
+		&comment.Deleted,
+		&comment.ReplyCount,
+	}
 
-	rows, err := commentStore.db.Query(ctx, query, postId)
-	if err != nil {
+	if err := row.Scan(append(dest, extra...)...); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	comments := []Comment{}
+	return &comment, nil
+}
+
+// NestComments arranges comments listed in thread order, as returned by
+// GetPage, into trees and returns the top level comments.
+func NestComments(comments []*Comment) []*Comment {
+	byId := make(map[int]*Comment, len(comments))
+	roots := []*Comment{}
+
+	for _, comment := range comments {
+		byId[comment.ID] = comment
+
+		if comment.ParentId != nil {
+			if parent, found := byId[*comment.ParentId]; found {
+				parent.Replies = append(parent.Replies, comment)
+				continue
+			}
+		}
 
-	for rows.Next() {
-		var comment Comment
-		err := rows.Scan(
-			&comment.ID,
-			&comment.PostId,
-			&comment.UserId,
-			&comment.Content,
-			&comment.CreatedAt,
-			&comment.UserName,
+		roots = append(roots, comment)
+	}
+
+	return roots
+}
+
+// ThreadLimit bounds the replies loaded under each listed comment. Replies
+// left out are paged with GetReplies.
+type ThreadLimit struct {
+	// PerComment is the most direct replies loaded under a comment.
+	PerComment int
+	// Depth is how many levels of replies are loaded below a listed comment.
+	Depth int
+}
+
+// threadQuery selects the comments $1 followed by their replies in thread
+// order: every comment is followed by its replies, oldest first, with at
+// most $3 replies under a comment and $4 levels below the listed comments.
+// Replies by users that blocked $2, or were blocked by them, are left out
+// together with their own replies.
+var threadQuery = `WITH RECURSIVE thread AS (
+			SELECT c.id, ARRAY[c.id] AS path, 0 AS level FROM comments c
+			WHERE c.id = ANY($1)
+			UNION ALL
+			SELECT reply.id, thread.path || reply.id, thread.level + 1 FROM thread
+			CROSS JOIN LATERAL (
+				SELECT c.id FROM comments c
+				WHERE c.parent_id = thread.id AND NOT ` + blockedBetween("$2", "c.user_id") + `
+				ORDER BY c.created_at, c.id
+				LIMIT $3
+			) reply
+			WHERE thread.level < $4
 		)
+		SELECT ` + commentColumns + `, thread.path FROM thread
+		JOIN comments c ON c.id = thread.id
+		LEFT JOIN users ON users.id = c.user_id
+		ORDER BY thread.path`
+
+// GetPage pages through the top level comments on a post oldest first, keyed
+// on (created_at, id). Each is followed by its replies in thread order, as far
+// as limit allows. Comments by users that blocked viewerId, or were blocked by
+// them, are left out together with their replies.
+func (commentStore *CommentStore) GetPage(
+	ctx context.Context,
+	postId int,
+	viewerId int,
+	query CursorQuery,
+	limit ThreadLimit,
+) (*Page[*Comment], error) {
+	return commentStore.pageThreads(ctx, "c.post_id = $1 AND c.parent_id IS NULL", postId, nil, viewerId, query, limit)
+}
+
+// GetReplies pages through the direct replies to a comment oldest first, like
+// GetPage does for top level comments. Paths start at the top level comment.
+func (commentStore *CommentStore) GetReplies(
+	ctx context.Context,
+	commentId int,
+	viewerId int,
+	query CursorQuery,
+	limit ThreadLimit,
+) (*Page[*Comment], error) {
+	ancestorsQuery := `WITH RECURSIVE ancestors AS (
+						   SELECT id, parent_id, depth FROM comments WHERE id = $1
+						   UNION ALL
+						   SELECT c.id, c.parent_id, c.depth FROM comments c
+						   JOIN ancestors ON c.id = ancestors.parent_id
+					   )
+					   SELECT array_agg(id ORDER BY depth) FROM ancestors`
+
+	var path []int
+	if err := commentStore.db.QueryRow(ctx, ancestorsQuery, commentId).Scan(&path); err != nil {
+		return nil, err
+	}
+
+	return commentStore.pageThreads(ctx, "c.parent_id = $1", commentId, path, viewerId, query, limit)
+}
+
+// pageThreads pages through the comments matching condition on $1 with their
+// replies. pathPrefix is prepended to every path.
+func (commentStore *CommentStore) pageThreads(
+	ctx context.Context,
+	condition string,
+	conditionArg int,
+	pathPrefix []int,
+	viewerId int,
+	query CursorQuery,
+	limit ThreadLimit,
+) (*Page[*Comment], error) {
+	sql := `SELECT c.created_at, c.id FROM comments c
+			WHERE ` + condition + ` AND NOT ` + blockedBetween("$2", "c.user_id") + `
+			AND ($3::timestamptz IS NULL OR (c.created_at, c.id) > ($3, $4))
+			ORDER BY c.created_at, c.id
+			LIMIT $5`
+
+	var afterCreatedAt *time.Time
+	var afterId int
+	if query.After != nil {
+		afterCreatedAt, afterId = &query.After.CreatedAt, query.After.ID
+	}
+
+	rows, err := commentStore.db.Query(ctx, sql, conditionArg, viewerId, afterCreatedAt, afterId, query.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	listed, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Cursor, error) {
+		var cursor Cursor
+		err := row.Scan(&cursor.CreatedAt, &cursor.ID)
+		return cursor, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	listedPage := newPage(listed, query.Limit, func(cursor Cursor) Cursor { return cursor })
+
+	listedIds := make([]int, len(listedPage.Items))
+	for i, cursor := range listedPage.Items {
+		listedIds[i] = cursor.ID
+	}
+
+	rows, err = commentStore.db.Query(ctx, threadQuery, listedIds, viewerId, limit.PerComment, limit.Depth)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Comment, error) {
+		var path []int
+		comment, err := scanComment(row, &path)
 		if err != nil {
 			return nil, err
 		}
-		comments = append(comments, comment)
+		comment.Path = append(append([]int{}, pathPrefix...), path...)
+		return comment, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	setRepliesCursors(comments)
+
+	return &Page[*Comment]{Items: comments, NextCursor: listedPage.NextCursor}, nil
+}
+
+// setRepliesCursors points RepliesCursor past the last loaded reply of every
+// comment that has more replies than were loaded.
+func setRepliesCursors(comments []*Comment) {
+	loaded := make(map[int]int, len(comments))
+	last := make(map[int]*Comment, len(comments))
+
+	for _, comment := range comments {
+		if comment.ParentId != nil {
+			loaded[*comment.ParentId]++
+			last[*comment.ParentId] = comment
+		}
+	}
+
+	for _, comment := range comments {
+		if reply, found := last[comment.ID]; found && comment.ReplyCount > loaded[comment.ID] {
+			comment.RepliesCursor = Cursor{CreatedAt: reply.CreatedAt, ID: reply.ID}.Encode()
+		}
 	}
+}
+
+func (commentStore *CommentStore) GetById(ctx context.Context, commentId int) (*Comment, error) {
+	query := `SELECT ` + commentColumns + ` FROM comments c
+			  LEFT JOIN users ON users.id = c.user_id
+			  WHERE c.id = $1`
 
-	return &comments, nil
+	comment, err := scanComment(commentStore.db.QueryRow(ctx, query, commentId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrorNotFound
+		}
+		return nil, err
+	}
+
+	return comment, nil
 }
 
+// Create adds a comment, or a reply when ParentId is set. The depth of a reply
+// is one more than its parent's. A parent that no longer exists gives
+// ErrorNotFound.
 func (commentStore *CommentStore) Create(ctx context.Context, comment *Comment) error {
-	query := `INSERT INTO comments (post_id, user_id, content)
-			  VALUES ($1,$2,$3)
-			  RETURNING id, created_at`
+	query := `INSERT INTO comments (post_id, user_id, content, parent_id, depth)
+			  VALUES ($1,$2,$3,$4,COALESCE((SELECT depth + 1 FROM comments WHERE id = $4), 0))
+			  RETURNING id, created_at, depth`
 
 	err := commentStore.db.QueryRow(
 		ctx,
@@ -63,14 +283,104 @@ func (commentStore *CommentStore) Create(ctx context.Context, comment *Comment)
 		comment.PostId,
 		comment.UserId,
 		comment.Content,
+		comment.ParentId,
 	).Scan(
 		&comment.ID,
 		&comment.CreatedAt,
+		&comment.Depth,
 	)
 
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		// The parent was deleted in the meantime.
+		return ErrorNotFound
+	}
+
+	return err
+}
+
+// Update saves the content of a comment and marks it as edited. Deleted
+// comments cannot be edited.
+func (commentStore *CommentStore) Update(ctx context.Context, comment *Comment) error {
+	query := `UPDATE comments
+			  SET content = $1, updated_at = NOW()
+			  WHERE id = $2 AND deleted_at IS NULL
+			  RETURNING updated_at`
+
+	err := commentStore.db.QueryRow(ctx, query, comment.Content, comment.ID).Scan(&comment.UpdatedAt)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrorNotFound
+		}
 		return err
 	}
 
 	return nil
 }
+
+// Delete removes a comment. A comment with replies is kept as a placeholder,
+// without its author and content, so the replies stay in place. Placeholders
+// left without replies are removed as well.
+func (commentStore *CommentStore) Delete(ctx context.Context, commentId int) error {
+	return withTransaction(commentStore.db, ctx, func(tx pgx.Tx) error {
+		// The lock keeps replies from being added until the comment is gone,
+		// so a comment is never deleted from under a new reply.
+		query := `SELECT parent_id, EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)
+				  FROM comments c
+				  WHERE c.id = $1 AND c.deleted_at IS NULL
+				  FOR UPDATE`
+
+		var parentId *int
+		var hasReplies bool
+		if err := tx.QueryRow(ctx, query, commentId).Scan(&parentId, &hasReplies); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrorNotFound
+			}
+			return err
+		}
+
+		if hasReplies {
+			query := `UPDATE comments SET user_id = NULL, content = '', deleted_at = NOW() WHERE id = $1`
+			_, err := tx.Exec(ctx, query, commentId)
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM comments WHERE id = $1`, commentId); err != nil {
+			return err
+		}
+
+		pruneQuery := `DELETE FROM comments
+					   WHERE id = $1 AND deleted_at IS NOT NULL
+					   AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = $1)
+					   RETURNING parent_id`
+
+		for parentId != nil {
+			err := tx.QueryRow(ctx, pruneQuery, *parentId).Scan(&parentId)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Export returns every comment written by the user, on any post.
+func (commentStore *CommentStore) Export(ctx context.Context, userId int) (any, error) {
+	query := `SELECT ` + commentColumns + ` FROM comments c
+			  LEFT JOIN users ON users.id = c.user_id
+			  WHERE c.user_id = $1
+			  ORDER BY c.created_at`
+
+	rows, err := commentStore.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Comment, error) {
+		return scanComment(row)
+	})
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// thread builds comments in thread order from id and parent id pairs, where
+// a parent of 0 means a top level comment.
+func thread(pairs ...[2]int) []*Comment {
+	comments := make([]*Comment, 0, len(pairs))
+	for _, pair := range pairs {
+		comment := &Comment{ID: pair[0], CreatedAt: time.Unix(int64(pair[0]), 0)}
+		if pair[1] != 0 {
+			parentId := pair[1]
+			comment.ParentId = &parentId
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments
+}
+
+// renderTree writes comments as "1(2(3),4),5".
+func renderTree(comments []*Comment) string {
+	parts := make([]string, 0, len(comments))
+	for _, comment := range comments {
+		part := strconv.Itoa(comment.ID)
+		if len(comment.Replies) > 0 {
+			part += "(" + renderTree(comment.Replies) + ")"
+		}
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func TestNestComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		comments []*Comment
+		want     string
+	}{
+		{"empty", nil, ""},
+		{"top level only", thread([2]int{1, 0}, [2]int{2, 0}), "1,2"},
+		{
+			"nested replies",
+			thread([2]int{1, 0}, [2]int{2, 1}, [2]int{3, 2}, [2]int{4, 1}, [2]int{5, 0}),
+			"1(2(3),4),5",
+		},
+		{
+			// The parent of 3 was left out because its author is blocked, so
+			// the reply is listed at the top level instead of being dropped.
+			"orphaned reply",
+			thread([2]int{1, 0}, [2]int{3, 2}, [2]int{4, 3}),
+			"1,3(4)",
+		},
+		{
+			// Paging replies lists them without their parent.
+			"replies page",
+			thread([2]int{2, 1}, [2]int{3, 1}),
+			"2,3",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := renderTree(NestComments(test.comments)); got != test.want {
+				t.Errorf("NestComments() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSetRepliesCursors(t *testing.T) {
+	tests := []struct {
+		name        string
+		comments    []*Comment
+		replyCounts map[int]int
+		want        map[int]int
+	}{
+		{
+			"all replies loaded",
+			thread([2]int{1, 0}, [2]int{2, 1}, [2]int{3, 1}),
+			map[int]int{1: 2},
+			map[int]int{},
+		},
+		{
+			"more replies than loaded",
+			thread([2]int{1, 0}, [2]int{2, 1}, [2]int{3, 1}, [2]int{4, 0}),
+			map[int]int{1: 5},
+			map[int]int{1: 3},
+		},
+		{
+			"no replies loaded",
+			thread([2]int{1, 0}),
+			map[int]int{1: 4},
+			map[int]int{},
+		},
+		{
+			"nested replies",
+			thread([2]int{1, 0}, [2]int{2, 1}, [2]int{3, 2}),
+			map[int]int{1: 1, 2: 2},
+			map[int]int{2: 3},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			byId := make(map[int]*Comment)
+			for _, comment := range test.comments {
+				comment.ReplyCount = test.replyCounts[comment.ID]
+				byId[comment.ID] = comment
+			}
+
+			setRepliesCursors(test.comments)
+
+			for _, comment := range test.comments {
+				want := ""
+				if lastId, found := test.want[comment.ID]; found {
+					last := byId[lastId]
+					want = Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+				}
+
+				if comment.RepliesCursor != want {
+					t.Errorf("comment %d replies cursor = %q, want %q", comment.ID, comment.RepliesCursor, want)
+				}
+			}
+		})
+	}
+}
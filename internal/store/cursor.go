@@ -0,0 +1,102 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in a list ordered by (created_at, id). Unlike an
+// offset it stays put when rows before it are added or removed.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+func (cursor Cursor) Encode() string {
+	raw := fmt.Sprintf("%d:%d", cursor.CreatedAt.UnixNano(), cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(encoded string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	nanos, id, found := strings.Cut(string(raw), ":")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	parsedNanos, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parsedId, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{CreatedAt: time.Unix(0, parsedNanos), ID: parsedId}, nil
+}
+
+type CursorQuery struct {
+	Limit int `json:"limit" validate:"gte=1,lte=50"`
+	After *Cursor
+}
+
+func (query *CursorQuery) Parse(r *http.Request) error {
+	queryValues := r.URL.Query()
+
+	limit := queryValues.Get("limit")
+	if limit != "" {
+		parsedLimit, err := strconv.Atoi(limit)
+		if err != nil {
+			return err
+		}
+		query.Limit = parsedLimit
+	}
+
+	cursor := queryValues.Get("cursor")
+	if cursor != "" {
+		after, err := DecodeCursor(cursor)
+		if err != nil {
+			return err
+		}
+		query.After = after
+	}
+
+	return nil
+}
+
+// Page is one page of a cursor paginated list. NextCursor is empty on the
+// last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// newPage trims rows fetched with a limit of query.Limit+1 and uses the last
+// item kept for the next cursor when there were more rows.
+func newPage[T any](rows []T, limit int, cursorOf func(T) Cursor) *Page[T] {
+	page := &Page[T]{Items: rows}
+
+	if len(rows) > limit {
+		page.Items = rows[:limit]
+		page.NextCursor = cursorOf(page.Items[limit-1]).Encode()
+	}
+
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+
+	return page
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor Cursor
+	}{
+		{"zero", Cursor{CreatedAt: time.Unix(0, 0), ID: 0}},
+		{"nanoseconds", Cursor{CreatedAt: time.Unix(1700000000, 123456789), ID: 42}},
+		{"before epoch", Cursor{CreatedAt: time.Unix(-10, 5), ID: 7}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decoded, err := DecodeCursor(test.cursor.Encode())
+			if err != nil {
+				t.Fatalf("DecodeCursor() error = %v", err)
+			}
+
+			if !decoded.CreatedAt.Equal(test.cursor.CreatedAt) || decoded.ID != test.cursor.ID {
+				t.Errorf("DecodeCursor() = %+v, want %+v", *decoded, test.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	valid := Cursor{CreatedAt: time.Unix(1700000000, 0), ID: 42}.Encode()
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", ""},
+		{"not base64", "not a cursor!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("1:22"))},
+		{"truncated", valid[:len(valid)-3]},
+		{"missing separator", encode("1700000000000000000")},
+		{"missing id", encode("1700000000000000000:")},
+		{"missing time", encode(":42")},
+		{"non numeric time", encode("yesterday:42")},
+		{"non numeric id", encode("1700000000000000000:abc")},
+		{"time out of range", encode("99999999999999999999:42")},
+		{"extra separator", encode("1:2:3")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cursor, err := DecodeCursor(test.encoded)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor(%q) = %+v, %v, want ErrInvalidCursor", test.encoded, cursor, err)
+			}
+		})
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	cursorOf := func(id int) Cursor {
+		return Cursor{CreatedAt: time.Unix(int64(id), 0), ID: id}
+	}
+
+	tests := []struct {
+		name       string
+		rows       []int
+		limit      int
+		wantItems  []int
+		wantCursor string
+	}{
+		{"nil rows", nil, 3, []int{}, ""},
+		{"fewer than limit", []int{1, 2}, 3, []int{1, 2}, ""},
+		{"exactly limit", []int{1, 2, 3}, 3, []int{1, 2, 3}, ""},
+		{"one more than limit", []int{1, 2, 3, 4}, 3, []int{1, 2, 3}, cursorOf(3).Encode()},
+		{"limit of one", []int{1, 2}, 1, []int{1}, cursorOf(1).Encode()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page := newPage(test.rows, test.limit, cursorOf)
+
+			if page.Items == nil {
+				t.Fatal("newPage() items are nil, want an empty slice")
+			}
+
+			if len(page.Items) != len(test.wantItems) {
+				t.Fatalf("newPage() items = %v, want %v", page.Items, test.wantItems)
+			}
+
+			for i := range page.Items {
+				if page.Items[i] != test.wantItems[i] {
+					t.Fatalf("newPage() items = %v, want %v", page.Items, test.wantItems)
+				}
+			}
+
+			if page.NextCursor != test.wantCursor {
+				t.Errorf("newPage() next cursor = %q, want %q", page.NextCursor, test.wantCursor)
+			}
+		})
+	}
+}
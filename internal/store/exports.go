@@ -0,0 +1,177 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// Exporter is implemented by every store that holds personal data, so each one
+// contributes its own section to a data export.
+type Exporter interface {
+	Export(ctx context.Context, userId int) (any, error)
+}
+
+type ExportSection struct {
+	Name     string
+	Exporter Exporter
+}
+
+// ExportSections lists the stores included in a personal data export.
+func (storage *Storage) ExportSections() []ExportSection {
+	return []ExportSection{
+		{Name: "profile", Exporter: storage.Users},
+		{Name: "posts", Exporter: storage.Posts},
+		{Name: "comments", Exporter: storage.Comments},
+		{Name: "follows", Exporter: storage.Followers},
+		{Name: "blocks", Exporter: storage.Blocks},
+		{Name: "sessions", Exporter: storage.RefreshTokens},
+		{Name: "api_keys", Exporter: storage.APIKeys},
+		{Name: "identities", Exporter: storage.Identities},
+	}
+}
+
+type DataExport struct {
+	ID          int        `json:"id"`
+	UserId      int        `json:"user_id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	Expiry      *time.Time `json:"expiry"`
+}
+
+type ExportStore struct {
+	db *pgxpool.Pool
+}
+
+// Create queues an export. Users can only have one pending export at a time
+// and get ErrorConflict otherwise.
+func (exportStore *ExportStore) Create(ctx context.Context, export *DataExport) error {
+	query := `INSERT INTO data_exports (user_id)
+			  VALUES ($1)
+			  RETURNING id, status, created_at`
+
+	err := exportStore.db.QueryRow(ctx, query, export.UserId).Scan(
+		&export.ID,
+		&export.Status,
+		&export.CreatedAt,
+	)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrorConflict
+	}
+
+	return err
+}
+
+func (exportStore *ExportStore) GetById(ctx context.Context, exportId int) (*DataExport, error) {
+	query := `SELECT id, user_id, status, created_at, started_at, completed_at, expiry
+			  FROM data_exports
+			  WHERE id = $1`
+
+	var export DataExport
+	err := exportStore.db.QueryRow(ctx, query, exportId).Scan(
+		&export.ID,
+		&export.UserId,
+		&export.Status,
+		&export.CreatedAt,
+		&export.StartedAt,
+		&export.CompletedAt,
+		&export.Expiry,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &export, nil
+}
+
+// Start marks a pending export as being built. Exports that are no longer
+// pending, because the sweep already failed them, give ErrorNotFound.
+func (exportStore *ExportStore) Start(ctx context.Context, export *DataExport) error {
+	query := `UPDATE data_exports
+			  SET started_at = NOW()
+			  WHERE id = $1 AND status = 'pending'
+			  RETURNING started_at`
+
+	err := exportStore.db.QueryRow(ctx, query, export.ID).Scan(&export.StartedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrorNotFound
+	}
+
+	return err
+}
+
+// Complete records the outcome of a pending export. Ready exports are kept
+// until exp has passed.
+func (exportStore *ExportStore) Complete(ctx context.Context, export *DataExport, status string, exp time.Duration) error {
+	query := `UPDATE data_exports
+			  SET status = $1, completed_at = NOW(), expiry = $2
+			  WHERE id = $3 AND status = 'pending'
+			  RETURNING status, completed_at, expiry`
+
+	var expiry *time.Time
+	if status == ExportReady {
+		t := time.Now().Add(exp)
+		expiry = &t
+	}
+
+	err := exportStore.db.QueryRow(ctx, query, status, expiry, export.ID).Scan(
+		&export.Status,
+		&export.CompletedAt,
+		&export.Expiry,
+	)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrorNotFound
+	}
+
+	return err
+}
+
+// FailStale fails pending exports whose build started more than buildTimeout
+// ago, or that were never started within queueTimeout, such as those
+// interrupted or dropped from the queue by a restart.
+func (exportStore *ExportStore) FailStale(ctx context.Context, buildTimeout, queueTimeout time.Duration) (int64, error) {
+	query := `UPDATE data_exports
+			  SET status = 'failed', completed_at = NOW()
+			  WHERE status = 'pending'
+			  AND (started_at < $1 OR (started_at IS NULL AND created_at < $2))`
+
+	now := time.Now()
+	cmd, err := exportStore.db.Exec(ctx, query, now.Add(-buildTimeout), now.Add(-queueTimeout))
+	if err != nil {
+		return 0, err
+	}
+
+	return cmd.RowsAffected(), nil
+}
+
+// DeleteExpired removes expired exports and returns their ids so the archives
+// can be deleted as well.
+func (exportStore *ExportStore) DeleteExpired(ctx context.Context) ([]int, error) {
+	rows, err := exportStore.db.Query(ctx, `DELETE FROM data_exports WHERE expiry < $1 RETURNING id`, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[int])
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -19,11 +20,14 @@ type FollowerStore struct {
 	db *pgxpool.Pool
 }
 
+// Follow makes followerID follow userId. It gives ErrBlocked when either user
+// has blocked the other.
 func (followerStore *FollowerStore) Follow(ctx context.Context, followerID int, userId int) error {
 	query := `INSERT INTO followers (user_id,follower_id)
-			VALUES ($1,$2)
-	`
-	_, err := followerStore.db.Exec(ctx, query, userId, followerID)
+			SELECT $1::bigint, $2::bigint
+			WHERE NOT ` + blockedBetween("$1", "$2")
+
+	cmd, err := followerStore.db.Exec(ctx, query, userId, followerID)
 
 	var pgErr *pgconn.PgError
 
@@ -34,14 +38,239 @@ func (followerStore *FollowerStore) Follow(ctx context.Context, followerID int,
 		return err
 	}
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrBlocked
+	}
+
+	return nil
 }
 
+// Unfollow removes the follow, or withdraws the follow request when it has
+// not been approved yet.
 func (followerStore *FollowerStore) Unfollow(ctx context.Context, followerID int, userId int) error {
-	query := `DELETE FROM followers 
-			  WHERE user_id = $1 AND follower_id = $2
-	`
-	_, err := followerStore.db.Exec(ctx, query, userId, followerID)
+	return withTransaction(followerStore.db, ctx, func(tx pgx.Tx) error {
+		query := `DELETE FROM followers 
+				  WHERE user_id = $1 AND follower_id = $2
+		`
+		if _, err := tx.Exec(ctx, query, userId, followerID); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, `DELETE FROM follow_requests WHERE user_id = $1 AND follower_id = $2`, userId, followerID)
+
+		return err
+	})
+}
+
+// FollowExport lists who follows the user and whom the user follows.
+type FollowExport struct {
+	Followers []Follower `json:"followers"`
+	Following []Follower `json:"following"`
+}
+
+// Export returns both sides of the user's follow relationships.
+func (followerStore *FollowerStore) Export(ctx context.Context, userId int) (any, error) {
+	var export FollowExport
+	var err error
+
+	export.Followers, err = followerStore.exportFollows(ctx, `WHERE user_id = $1`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	export.Following, err = followerStore.exportFollows(ctx, `WHERE follower_id = $1`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+func (followerStore *FollowerStore) exportFollows(ctx context.Context, condition string, userId int) ([]Follower, error) {
+	query := `SELECT user_id::text, follower_id::text, created_at FROM followers ` + condition + ` ORDER BY created_at`
+
+	rows, err := followerStore.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Follower, error) {
+		var follower Follower
+		err := row.Scan(&follower.UserId, &follower.FollowerId, &follower.CreatedAt)
+		return follower, err
+	})
+}
+
+// UserSummary is the short form of a user shown in lists.
+type UserSummary struct {
+	ID          int       `json:"id"`
+	UserName    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url"`
+	FollowedAt  time.Time `json:"followed_at"`
+}
+
+// GetFollowers lists the users following userId, most recent first.
+func (followerStore *FollowerStore) GetFollowers(
+	ctx context.Context,
+	userId int,
+	query CursorQuery,
+) (*Page[*UserSummary], error) {
+	return followerStore.listFollows(ctx, "followers", "f.follower_id", "f.user_id", userId, query)
+}
+
+// GetFollowing lists the users userId follows, most recent first.
+func (followerStore *FollowerStore) GetFollowing(
+	ctx context.Context,
+	userId int,
+	query CursorQuery,
+) (*Page[*UserSummary], error) {
+	return followerStore.listFollows(ctx, "followers", "f.user_id", "f.follower_id", userId, query)
+}
+
+// GetFollowRequests lists the pending requests to follow userId, most recent
+// first. FollowedAt is when the request was made.
+func (followerStore *FollowerStore) GetFollowRequests(
+	ctx context.Context,
+	userId int,
+	query CursorQuery,
+) (*Page[*UserSummary], error) {
+	return followerStore.listFollows(ctx, "follow_requests", "f.follower_id", "f.user_id", userId, query)
+}
+
+// listFollows returns the users in column listed of the rows of table whose
+// column filter equals userId, keyed on (created_at, user id).
+func (followerStore *FollowerStore) listFollows(
+	ctx context.Context,
+	table string,
+	listed string,
+	filter string,
+	userId int,
+	query CursorQuery,
+) (*Page[*UserSummary], error) {
+	sql := `SELECT u.id, u.username, u.display_name, u.avatar_url, f.created_at
+			FROM ` + table + ` f
+			JOIN users u ON u.id = ` + listed + `
+			WHERE ` + filter + ` = $1
+			AND ($2::timestamptz IS NULL OR (f.created_at, u.id) < ($2, $3))
+			ORDER BY f.created_at DESC, u.id DESC
+			LIMIT $4`
+
+	var afterCreatedAt *time.Time
+	var afterId int
+	if query.After != nil {
+		afterCreatedAt, afterId = &query.After.CreatedAt, query.After.ID
+	}
+
+	rows, err := followerStore.db.Query(ctx, sql, userId, afterCreatedAt, afterId, query.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*UserSummary, error) {
+		var summary UserSummary
+		if err := row.Scan(
+			&summary.ID,
+			&summary.UserName,
+			&summary.DisplayName,
+			&summary.AvatarURL,
+			&summary.FollowedAt,
+		); err != nil {
+			return nil, err
+		}
+		return &summary, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newPage(summaries, query.Limit, func(summary *UserSummary) Cursor {
+		return Cursor{CreatedAt: summary.FollowedAt, ID: summary.ID}
+	}), nil
+}
+
+func (followerStore *FollowerStore) IsFollowing(ctx context.Context, followerId int, userId int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)`
+
+	var following bool
+	err := followerStore.db.QueryRow(ctx, query, userId, followerId).Scan(&following)
+
+	return following, err
+}
+
+// RequestFollow asks userId to approve followerId as a follower. A request that
+// is already pending, or an existing follow, gives ErrorConflict, and a block
+// between the users gives ErrBlocked.
+func (followerStore *FollowerStore) RequestFollow(ctx context.Context, followerId int, userId int) error {
+	blocked, err := isBlocked(ctx, followerStore.db, followerId, userId)
+	if err != nil {
+		return err
+	}
+
+	if blocked {
+		return ErrBlocked
+	}
+
+	query := `INSERT INTO follow_requests (user_id, follower_id)
+			  SELECT $1, $2
+			  WHERE NOT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)`
+
+	cmd, err := followerStore.db.Exec(ctx, query, userId, followerId)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrorConflict
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrorConflict
+	}
+
+	return nil
+}
+
+// ApproveFollowRequest turns a pending request into a follow.
+func (followerStore *FollowerStore) ApproveFollowRequest(ctx context.Context, userId int, followerId int) error {
+	return withTransaction(followerStore.db, ctx, func(tx pgx.Tx) error {
+		if err := deleteFollowRequest(ctx, tx, userId, followerId); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO followers (user_id, follower_id)
+				  VALUES ($1,$2)
+				  ON CONFLICT DO NOTHING`
+
+		_, err := tx.Exec(ctx, query, userId, followerId)
+
+		return err
+	})
+}
+
+func (followerStore *FollowerStore) RejectFollowRequest(ctx context.Context, userId int, followerId int) error {
+	return withTransaction(followerStore.db, ctx, func(tx pgx.Tx) error {
+		return deleteFollowRequest(ctx, tx, userId, followerId)
+	})
+}
+
+func deleteFollowRequest(ctx context.Context, tx pgx.Tx, userId int, followerId int) error {
+	query := `DELETE FROM follow_requests WHERE user_id = $1 AND follower_id = $2`
+
+	cmd, err := tx.Exec(ctx, query, userId, followerId)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrorNotFound
+	}
 
-	return err
+	return nil
 }
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Identity links an account at an external OpenID Connect provider to a user.
+type Identity struct {
+	ID        int       `json:"id"`
+	UserId    int       `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type IdentityStore struct {
+	db    *pgxpool.Pool
+	users *UserStore
+}
+
+func (identityStore *IdentityStore) GetUser(ctx context.Context, provider string, subject string) (*User, error) {
+	query := `SELECT u.id FROM users u
+			  JOIN user_identities ui ON ui.user_id = u.id
+			  WHERE ui.provider = $1 AND ui.subject = $2`
+
+	var userId int
+	if err := identityStore.db.QueryRow(ctx, query, provider, subject).Scan(&userId); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return identityStore.users.GetUserById(ctx, userId)
+}
+
+// Link attaches identity to an existing, activated user.
+func (identityStore *IdentityStore) Link(ctx context.Context, user *User, identity *Identity) error {
+	return withTransaction(identityStore.db, ctx, func(tx pgx.Tx) error {
+		identity.UserId = user.ID
+		return identityStore.create(ctx, tx, identity)
+	})
+}
+
+// CreateUser registers an active user that signs in through identity. An
+// account with the same email that was never activated is deleted first:
+// whoever registered it never proved they own the address, so its password
+// must not carry over to the provider's user.
+func (identityStore *IdentityStore) CreateUser(ctx context.Context, user *User, identity *Identity) error {
+	return withTransaction(identityStore.db, ctx, func(tx pgx.Tx) error {
+		if err := identityStore.deleteInactive(ctx, tx, user.Email); err != nil {
+			return err
+		}
+
+		if err := identityStore.users.Create(ctx, tx, user); err != nil {
+			return err
+		}
+
+		user.IsActive = true
+		if err := identityStore.users.update(ctx, tx, user); err != nil {
+			return err
+		}
+
+		identity.UserId = user.ID
+		return identityStore.create(ctx, tx, identity)
+	})
+}
+
+func (identityStore *IdentityStore) deleteInactive(ctx context.Context, tx pgx.Tx, email string) error {
+	query := `DELETE FROM user_invitations
+			  WHERE user_id IN (SELECT id FROM users WHERE email = $1 AND is_active = FALSE)`
+
+	if _, err := tx.Exec(ctx, query, email); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx, `DELETE FROM users WHERE email = $1 AND is_active = FALSE`, email)
+
+	return err
+}
+
+func (identityStore *IdentityStore) create(ctx context.Context, tx pgx.Tx, identity *Identity) error {
+	query := `INSERT INTO user_identities (user_id, provider, subject, email)
+			  VALUES ($1,$2,$3,$4)
+			  RETURNING id, created_at`
+
+	err := tx.QueryRow(
+		ctx,
+		query,
+		identity.UserId,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+	).Scan(&identity.ID, &identity.CreatedAt)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrorConflict
+	}
+
+	return err
+}
+
+func (identityStore *IdentityStore) CreateLoginState(
+	ctx context.Context,
+	state string,
+	codeVerifier string,
+	nonce string,
+	exp time.Duration,
+) error {
+	query := `INSERT INTO oidc_login_states (state, code_verifier, nonce, expiry)
+			  VALUES ($1,$2,$3,$4)`
+
+	_, err := identityStore.db.Exec(ctx, query, state, codeVerifier, nonce, time.Now().Add(exp))
+
+	return err
+}
+
+// ConsumeLoginState deletes a pending login and returns its PKCE verifier and nonce.
+func (identityStore *IdentityStore) ConsumeLoginState(ctx context.Context, state string) (string, string, error) {
+	query := `DELETE FROM oidc_login_states
+			  WHERE state = $1 AND expiry > $2
+			  RETURNING code_verifier, nonce`
+
+	var codeVerifier, nonce string
+	if err := identityStore.db.QueryRow(ctx, query, state, time.Now()).Scan(&codeVerifier, &nonce); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return "", "", ErrorNotFound
+		default:
+			return "", "", err
+		}
+	}
+
+	return codeVerifier, nonce, nil
+}
+
+func (identityStore *IdentityStore) DeleteExpiredLoginStates(ctx context.Context) (int64, error) {
+	cmd, err := identityStore.db.Exec(ctx, `DELETE FROM oidc_login_states WHERE expiry < $1`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return cmd.RowsAffected(), nil
+}
+
+// Export returns the external identities linked to the user.
+func (identityStore *IdentityStore) Export(ctx context.Context, userId int) (any, error) {
+	query := `SELECT id, user_id, provider, subject, email, created_at
+			  FROM user_identities
+			  WHERE user_id = $1
+			  ORDER BY created_at`
+
+	rows, err := identityStore.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Identity, error) {
+		var identity Identity
+		err := row.Scan(
+			&identity.ID,
+			&identity.UserId,
+			&identity.Provider,
+			&identity.Subject,
+			&identity.Email,
+			&identity.CreatedAt,
+		)
+		return identity, err
+	})
+}
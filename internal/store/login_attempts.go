@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type LoginAttemptStore struct {
+	db *pgxpool.Pool
+}
+
+// LockedUntil returns the latest lockout among keys, or the zero time when
+// none of them is locked.
+func (loginAttemptStore *LoginAttemptStore) LockedUntil(ctx context.Context, keys ...string) (time.Time, error) {
+	query := `SELECT MAX(locked_until) FROM login_attempts
+			  WHERE key = ANY($1) AND locked_until > $2`
+
+	var lockedUntil *time.Time
+	if err := loginAttemptStore.db.QueryRow(ctx, query, keys, time.Now()).Scan(&lockedUntil); err != nil {
+		return time.Time{}, err
+	}
+
+	if lockedUntil == nil {
+		return time.Time{}, nil
+	}
+
+	return *lockedUntil, nil
+}
+
+// RecordFailure counts a failed attempt for key and returns the number of
+// consecutive failures. Failures older than window start the count over.
+func (loginAttemptStore *LoginAttemptStore) RecordFailure(
+	ctx context.Context,
+	key string,
+	window time.Duration,
+) (int, error) {
+	query := `INSERT INTO login_attempts (key, failures, last_failure_at)
+			  VALUES ($1, 1, NOW())
+			  ON CONFLICT (key) DO UPDATE SET
+				failures = CASE
+					WHEN login_attempts.last_failure_at < $2 THEN 1
+					ELSE login_attempts.failures + 1
+				END,
+				last_failure_at = NOW()
+			  RETURNING failures`
+
+	var failures int
+	if err := loginAttemptStore.db.QueryRow(ctx, query, key, time.Now().Add(-window)).Scan(&failures); err != nil {
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+func (loginAttemptStore *LoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	query := `UPDATE login_attempts SET locked_until = $1 WHERE key = $2`
+
+	_, err := loginAttemptStore.db.Exec(ctx, query, until, key)
+
+	return err
+}
+
+func (loginAttemptStore *LoginAttemptStore) Reset(ctx context.Context, key string) error {
+	query := `DELETE FROM login_attempts WHERE key = $1`
+
+	_, err := loginAttemptStore.db.Exec(ctx, query, key)
+
+	return err
+}
+
+func (loginAttemptStore *LoginAttemptStore) DeleteStale(ctx context.Context, olderThan time.Duration) (int64, error) {
+	query := `DELETE FROM login_attempts
+			  WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < NOW())`
+
+	cmd, err := loginAttemptStore.db.Exec(ctx, query, time.Now().Add(-olderThan))
+	if err != nil {
+		return 0, err
+	}
+
+	return cmd.RowsAffected(), nil
+}
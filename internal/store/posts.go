@@ -17,7 +17,6 @@ type Post struct {
 	Tags      []string  `json:"tags"`
 	UpdatedAt time.Time `json:"updated_at"`
 	CreatedAt time.Time `json:"created_at"`
-	Comments  []Comment `json:"comments"`
 	Version   int       `json:"version"`
 }
 
@@ -34,6 +33,10 @@ type PostStore struct {
 	db *pgxpool.Pool
 }
 
+// GetUserFeed returns posts by the users that userId follows. Follows of private
+// accounts only exist once approved, so their posts never reach non-followers.
+// Posts by users that userId muted, or that blocked userId or were blocked by
+// them, are left out.
 func (postStore *PostStore) GetUserFeed(
 	ctx context.Context,
 	userId int,
@@ -56,13 +59,15 @@ func (postStore *PostStore) GetUserFeed(
 				FROM comments
 				GROUP BY post_id
 			) comment_counts ON comment_counts.post_id = p.id
-			JOIN followers f ON f.follower_id = p.user_id AND f.user_id = $1
+			JOIN followers f ON f.user_id = p.user_id AND f.follower_id = $1
 			LEFT JOIN users u ON u.id = p.user_id
 			WHERE 
 				(p.title ILIKE '%'|| $4 || '%' OR p.content ILIKE '%'|| $4 || '%') AND
 				(p.tags @> $5 OR p.tags @> '{}') AND
 				(p.created_at >= $6 OR $6 IS NULL) AND
-				(p.created_at < $7 OR $7 IS NULL)
+				(p.created_at < $7 OR $7 IS NULL) AND
+				NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.user_id = $1 AND m.muted_id = p.user_id) AND
+				NOT ` + blockedBetween("$1", "p.user_id") + `
 			ORDER BY p.created_at ` + pagination.Sort + `
 			LIMIT $2 OFFSET $3
 			`
@@ -201,3 +206,33 @@ func (postStore *PostStore) Update(ctx context.Context, post *Post) error {
 
 	return nil
 }
+
+// Export returns every post written by the user.
+func (postStore *PostStore) Export(ctx context.Context, userId int) (any, error) {
+	query := `SELECT id, title, content, user_id, tags, created_at, updated_at, version
+			  FROM posts
+			  WHERE user_id = $1
+			  ORDER BY created_at`
+
+	rows, err := postStore.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Post, error) {
+		var post Post
+		if err := row.Scan(
+			&post.ID,
+			&post.Title,
+			&post.Content,
+			&post.UserId,
+			&post.Tags,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.Version,
+		); err != nil {
+			return nil, err
+		}
+		return &post, nil
+	})
+}
@@ -0,0 +1,177 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrRefreshTokenReused = errors.New("refresh token has already been used")
+
+type RefreshTokenStore struct {
+	db *pgxpool.Pool
+}
+
+func (refreshTokenStore *RefreshTokenStore) Create(
+	ctx context.Context,
+	token string,
+	userId int,
+	exp time.Duration,
+) error {
+	query := `INSERT INTO refresh_tokens (token, user_id, family_id, expiry)
+			  VALUES ($1,$2,$3,$4)`
+
+	_, err := refreshTokenStore.db.Exec(
+		ctx,
+		query,
+		token,
+		userId,
+		uuid.New().String(),
+		time.Now().Add(exp),
+	)
+
+	return err
+}
+
+// Rotate revokes token and issues newToken in the same family. Presenting a
+// token that was already rotated revokes every token in its family.
+func (refreshTokenStore *RefreshTokenStore) Rotate(
+	ctx context.Context,
+	token string,
+	newToken string,
+	exp time.Duration,
+) (int, error) {
+	var userId int
+	var reused bool
+
+	err := withTransaction(refreshTokenStore.db, ctx, func(tx pgx.Tx) error {
+		query := `SELECT user_id, expiry, revoked_at
+				  FROM refresh_tokens
+				  WHERE token = $1
+				  FOR UPDATE`
+
+		var expiry time.Time
+		var revokedAt *time.Time
+
+		if err := tx.QueryRow(ctx, query, token).Scan(&userId, &expiry, &revokedAt); err != nil {
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+				return ErrorNotFound
+			default:
+				return err
+			}
+		}
+
+		if revokedAt != nil {
+			reused = true
+			return refreshTokenStore.revokeFamily(ctx, tx, token)
+		}
+
+		if expiry.Before(time.Now()) {
+			return ErrorNotFound
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE refresh_tokens SET revoked_at = NOW() WHERE token = $1`, token); err != nil {
+			return err
+		}
+
+		query = `INSERT INTO refresh_tokens (token, user_id, family_id, expiry)
+				 SELECT $1, user_id, family_id, $2
+				 FROM refresh_tokens
+				 WHERE token = $3`
+
+		if _, err := tx.Exec(ctx, query, newToken, time.Now().Add(exp), token); err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if reused {
+		return 0, ErrRefreshTokenReused
+	}
+
+	return userId, nil
+}
+
+func (refreshTokenStore *RefreshTokenStore) revokeFamily(ctx context.Context, tx pgx.Tx, token string) error {
+	query := `UPDATE refresh_tokens
+			  SET revoked_at = NOW()
+			  WHERE revoked_at IS NULL AND family_id = (
+				SELECT family_id FROM refresh_tokens WHERE token = $1
+			  )`
+
+	_, err := tx.Exec(ctx, query, token)
+
+	return err
+}
+
+// Revoke revokes the family of token when it belongs to userId. Tokens of other
+// users are left alone.
+func (refreshTokenStore *RefreshTokenStore) Revoke(ctx context.Context, userId int, token string) error {
+	query := `UPDATE refresh_tokens
+			  SET revoked_at = NOW()
+			  WHERE user_id = $2 AND revoked_at IS NULL AND family_id = (
+				SELECT family_id FROM refresh_tokens WHERE token = $1 AND user_id = $2
+			  )`
+
+	_, err := refreshTokenStore.db.Exec(ctx, query, token, userId)
+
+	return err
+}
+
+func (refreshTokenStore *RefreshTokenStore) RevokeAllForUser(ctx context.Context, userId int) error {
+	query := `UPDATE refresh_tokens
+			  SET revoked_at = NOW()
+			  WHERE user_id = $1 AND revoked_at IS NULL`
+
+	_, err := refreshTokenStore.db.Exec(ctx, query, userId)
+
+	return err
+}
+
+func (refreshTokenStore *RefreshTokenStore) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `DELETE FROM refresh_tokens WHERE expiry < $1`
+
+	cmd, err := refreshTokenStore.db.Exec(ctx, query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return cmd.RowsAffected(), nil
+}
+
+// Session describes a refresh token family without the token itself.
+type Session struct {
+	FamilyId  string     `json:"family_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Expiry    time.Time  `json:"expiry"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// Export returns the refresh tokens issued to the user.
+func (refreshTokenStore *RefreshTokenStore) Export(ctx context.Context, userId int) (any, error) {
+	query := `SELECT family_id::text, created_at, expiry, revoked_at
+			  FROM refresh_tokens
+			  WHERE user_id = $1
+			  ORDER BY created_at`
+
+	rows, err := refreshTokenStore.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Session, error) {
+		var session Session
+		err := row.Scan(&session.FamilyId, &session.CreatedAt, &session.Expiry, &session.RevokedAt)
+		return session, err
+	})
+}
@@ -14,39 +14,171 @@ var (
 	ErrorConflict = errors.New("conflict")
 )
 
+// Storage groups the stores used by the API. Every store is an interface so
+// tests can swap in fakes that embed it and override only what they call.
 type Storage struct {
-	Posts interface {
-		Create(context.Context, *Post) error
-		GetPostById(context.Context, int) (*Post, error)
-		Delete(context.Context, int) error
-		Update(context.Context, *Post) error
-		GetUserFeed(context.Context, int, PaginatedQuery) ([]*PostWithMetaData, error)
-	}
+	Posts         Posts
+	Users         Users
+	Comments      Comments
+	Followers     Followers
+	Blocks        Blocks
+	RefreshTokens RefreshTokens
+	TwoFactor     TwoFactor
+	LoginAttempts LoginAttempts
+	APIKeys       APIKeys
+	Identities    Identities
+	Exports       Exports
+	Revocations   Revocations
+}
 
-	Users interface {
-		Create(ctx context.Context, txn pgx.Tx, user *User) error
-		GetUserById(context.Context, int) (*User, error)
-		CreateAndInvite(context.Context, *User, string, time.Duration) error
-		Activate(context.Context, string) error
-	}
+type Posts interface {
+	Exporter
+	Create(context.Context, *Post) error
+	GetPostById(context.Context, int) (*Post, error)
+	Delete(context.Context, int) error
+	Update(context.Context, *Post) error
+	GetUserFeed(context.Context, int, PaginatedQuery) ([]*PostWithMetaData, error)
+}
 
-	Comments interface {
-		GetByPostId(context.Context, int) (*[]Comment, error)
-		Create(context.Context, *Comment) error
-	}
+type Users interface {
+	Exporter
+	Create(ctx context.Context, txn pgx.Tx, user *User) error
+	GetUserById(context.Context, int) (*User, error)
+	GetByEmail(context.Context, string) (*User, error)
+	CreateAndInvite(context.Context, *User, string, time.Duration) error
+	Activate(context.Context, string) error
+	Delete(context.Context, int) error
+	CreatePasswordReset(ctx context.Context, userId int, token string, exp time.Duration) error
+	GetByPasswordReset(ctx context.Context, token string) (*User, error)
+	ResetPassword(ctx context.Context, token string, newPassword string) (*User, error)
+	RotateInvitation(ctx context.Context, userId int, token string, invitationExp time.Duration) error
+	DeleteExpiredInvitations(context.Context) (int64, error)
+	PurgeInactive(ctx context.Context, olderThan time.Duration) (int64, error)
+	CreateMagicLink(ctx context.Context, userId int, token string, exp time.Duration) error
+	RedeemMagicLink(ctx context.Context, token string) (*User, error)
+	DeleteExpiredMagicLinks(context.Context) (int64, error)
+	Update(context.Context, *User) error
+	GetProfile(context.Context, int) (*Profile, error)
+	CreateEmailChange(ctx context.Context, userId int, email string, token string, exp time.Duration) error
+	ConfirmEmailChange(ctx context.Context, token string) (*User, error)
+	DeleteExpiredEmailChanges(context.Context) (int64, error)
+	ScheduleDeletion(ctx context.Context, userId int, grace time.Duration) (time.Time, error)
+	CancelDeletion(ctx context.Context, userId int) (bool, error)
+	DeleteScheduled(context.Context) (int64, error)
+}
 
-	Followers interface {
-		Follow(ctx context.Context, followerId int, userId int) error
-		Unfollow(ctx context.Context, followerId int, userId int) error
-	}
+type Comments interface {
+	Exporter
+	GetPage(ctx context.Context, postId int, viewerId int, query CursorQuery, limit ThreadLimit) (*Page[*Comment], error)
+	GetReplies(ctx context.Context, commentId int, viewerId int, query CursorQuery, limit ThreadLimit) (*Page[*Comment], error)
+	GetById(context.Context, int) (*Comment, error)
+	Create(context.Context, *Comment) error
+	Update(context.Context, *Comment) error
+	Delete(context.Context, int) error
+}
+
+type Followers interface {
+	Exporter
+	Follow(ctx context.Context, followerId int, userId int) error
+	Unfollow(ctx context.Context, followerId int, userId int) error
+	GetFollowers(ctx context.Context, userId int, query CursorQuery) (*Page[*UserSummary], error)
+	GetFollowing(ctx context.Context, userId int, query CursorQuery) (*Page[*UserSummary], error)
+	IsFollowing(ctx context.Context, followerId int, userId int) (bool, error)
+	RequestFollow(ctx context.Context, followerId int, userId int) error
+	GetFollowRequests(ctx context.Context, userId int, query CursorQuery) (*Page[*UserSummary], error)
+	ApproveFollowRequest(ctx context.Context, userId int, followerId int) error
+	RejectFollowRequest(ctx context.Context, userId int, followerId int) error
+}
+
+type Blocks interface {
+	Exporter
+	Block(ctx context.Context, userId int, blockedId int) error
+	Unblock(ctx context.Context, userId int, blockedId int) error
+	IsBlocked(ctx context.Context, userId int, otherId int) (bool, error)
+	Mute(ctx context.Context, userId int, mutedId int) error
+	Unmute(ctx context.Context, userId int, mutedId int) error
+}
+
+type RefreshTokens interface {
+	Exporter
+	Create(ctx context.Context, token string, userId int, exp time.Duration) error
+	Rotate(ctx context.Context, token string, newToken string, exp time.Duration) (int, error)
+	Revoke(ctx context.Context, userId int, token string) error
+	RevokeAllForUser(ctx context.Context, userId int) error
+	DeleteExpired(context.Context) (int64, error)
+}
+
+type TwoFactor interface {
+	SetPendingSecret(ctx context.Context, userId int, secret string) error
+	GetSecret(ctx context.Context, userId int) (string, error)
+	Enable(ctx context.Context, userId int, recoveryCodes []string) error
+	UseTOTPStep(ctx context.Context, userId int, step int64) error
+	UseRecoveryCode(ctx context.Context, userId int, code string) error
+	CreateChallenge(ctx context.Context, userId int, token string, exp time.Duration) error
+	AttemptChallenge(ctx context.Context, token string) (int, error)
+	ConsumeChallenge(ctx context.Context, token string) (int, error)
+	DeleteExpiredChallenges(context.Context) (int64, error)
+}
+
+type LoginAttempts interface {
+	LockedUntil(ctx context.Context, keys ...string) (time.Time, error)
+	RecordFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	DeleteStale(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+type APIKeys interface {
+	Exporter
+	Create(ctx context.Context, apiKey *APIKey, key string) error
+	GetByUser(ctx context.Context, userId int) ([]*APIKey, error)
+	Authenticate(ctx context.Context, key string) (*APIKey, error)
+	Revoke(ctx context.Context, userId int, keyId int) error
+	RevokeAllForUser(ctx context.Context, userId int) error
+}
+
+type Identities interface {
+	Exporter
+	GetUser(ctx context.Context, provider string, subject string) (*User, error)
+	Link(ctx context.Context, user *User, identity *Identity) error
+	CreateUser(ctx context.Context, user *User, identity *Identity) error
+	CreateLoginState(ctx context.Context, state string, codeVerifier string, nonce string, exp time.Duration) error
+	ConsumeLoginState(ctx context.Context, state string) (string, string, error)
+	DeleteExpiredLoginStates(context.Context) (int64, error)
+}
+
+type Exports interface {
+	Create(context.Context, *DataExport) error
+	GetById(context.Context, int) (*DataExport, error)
+	Start(context.Context, *DataExport) error
+	Complete(ctx context.Context, export *DataExport, status string, exp time.Duration) error
+	FailStale(ctx context.Context, buildTimeout, queueTimeout time.Duration) (int64, error)
+	DeleteExpired(context.Context) ([]int, error)
+}
+
+type Revocations interface {
+	Revoke(ctx context.Context, userId int, jti string, expiry time.Time) error
+	RevokeAll(ctx context.Context, userId int, tokenExp time.Duration) error
+	IsRevoked(ctx context.Context, userId int, jti string, issuedAt time.Time) (bool, error)
+	DeleteExpired(context.Context) (int64, error)
 }
 
 func NewStorage(db *pgxpool.Pool) *Storage {
+	users := &UserStore{db}
+
 	return &Storage{
-		Posts:     &PostStore{db},
-		Users:     &UserStore{db},
-		Comments:  &CommentStore{db},
-		Followers: &FollowerStore{db},
+		Posts:         &PostStore{db},
+		Users:         users,
+		Comments:      &CommentStore{db},
+		Followers:     &FollowerStore{db},
+		Blocks:        &BlockStore{db},
+		RefreshTokens: &RefreshTokenStore{db},
+		Revocations:   &RevocationStore{db},
+		TwoFactor:     &TwoFactorStore{db},
+		LoginAttempts: &LoginAttemptStore{db},
+		APIKeys:       &APIKeyStore{db},
+		Identities:    &IdentityStore{db, users},
+		Exports:       &ExportStore{db},
 	}
 }
 
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const maxChallengeAttempts = 5
+
+type TwoFactorStore struct {
+	db *pgxpool.Pool
+}
+
+// SetPendingSecret stores a secret that only takes effect once Enable is
+// called. Users that already have two-factor enabled get ErrorConflict.
+func (twoFactorStore *TwoFactorStore) SetPendingSecret(ctx context.Context, userId int, secret string) error {
+	query := `UPDATE users SET totp_secret = $1
+			  WHERE id = $2 AND totp_enabled = FALSE`
+
+	cmd, err := twoFactorStore.db.Exec(ctx, query, secret, userId)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrorConflict
+	}
+
+	return nil
+}
+
+func (twoFactorStore *TwoFactorStore) GetSecret(ctx context.Context, userId int) (string, error) {
+	query := `SELECT totp_secret FROM users WHERE id = $1 AND totp_secret IS NOT NULL`
+
+	var secret string
+	if err := twoFactorStore.db.QueryRow(ctx, query, userId).Scan(&secret); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return "", ErrorNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return secret, nil
+}
+
+// Enable turns on two-factor authentication and replaces the user's recovery
+// codes with the given hashes.
+func (twoFactorStore *TwoFactorStore) Enable(ctx context.Context, userId int, recoveryCodes []string) error {
+	return withTransaction(twoFactorStore.db, ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `UPDATE users SET totp_enabled = TRUE WHERE id = $1`, userId); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userId); err != nil {
+			return err
+		}
+
+		for _, code := range recoveryCodes {
+			query := `INSERT INTO recovery_codes (user_id, code) VALUES ($1,$2)`
+			if _, err := tx.Exec(ctx, query, userId, code); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// UseTOTPStep records step as the last TOTP time step the user signed in with.
+// Steps at or before the recorded one give ErrorConflict, so a code cannot be
+// used twice.
+func (twoFactorStore *TwoFactorStore) UseTOTPStep(ctx context.Context, userId int, step int64) error {
+	query := `UPDATE users SET totp_last_step = $1
+			  WHERE id = $2 AND (totp_last_step IS NULL OR totp_last_step < $1)`
+
+	cmd, err := twoFactorStore.db.Exec(ctx, query, step, userId)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrorConflict
+	}
+
+	return nil
+}
+
+func (twoFactorStore *TwoFactorStore) UseRecoveryCode(ctx context.Context, userId int, code string) error {
+	query := `UPDATE recovery_codes SET used_at = NOW()
+			  WHERE user_id = $1 AND code = $2 AND used_at IS NULL`
+
+	cmd, err := twoFactorStore.db.Exec(ctx, query, userId, code)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrorNotFound
+	}
+
+	return nil
+}
+
+func (twoFactorStore *TwoFactorStore) CreateChallenge(
+	ctx context.Context,
+	userId int,
+	token string,
+	exp time.Duration,
+) error {
+	query := `INSERT INTO two_factor_challenges (token, user_id, expiry) VALUES ($1,$2,$3)`
+
+	_, err := twoFactorStore.db.Exec(ctx, query, token, userId, time.Now().Add(exp))
+
+	return err
+}
+
+// AttemptChallenge records an attempt against a live challenge and returns its
+// user. Challenges stop accepting attempts after maxChallengeAttempts.
+func (twoFactorStore *TwoFactorStore) AttemptChallenge(ctx context.Context, token string) (int, error) {
+	query := `UPDATE two_factor_challenges
+			  SET attempts = attempts + 1
+			  WHERE token = $1 AND expiry > $2 AND attempts < $3
+			  RETURNING user_id`
+
+	var userId int
+	if err := twoFactorStore.db.QueryRow(ctx, query, token, time.Now(), maxChallengeAttempts).Scan(&userId); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return 0, ErrorNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return userId, nil
+}
+
+// ConsumeChallenge deletes a live challenge and returns its user. Only one of
+// several concurrent calls for the same challenge succeeds, the others get
+// ErrorNotFound.
+func (twoFactorStore *TwoFactorStore) ConsumeChallenge(ctx context.Context, token string) (int, error) {
+	query := `DELETE FROM two_factor_challenges
+			  WHERE token = $1 AND expiry > $2
+			  RETURNING user_id`
+
+	var userId int
+	if err := twoFactorStore.db.QueryRow(ctx, query, token, time.Now()).Scan(&userId); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return 0, ErrorNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return userId, nil
+}
+
+func (twoFactorStore *TwoFactorStore) DeleteExpiredChallenges(ctx context.Context) (int64, error) {
+	cmd, err := twoFactorStore.db.Exec(ctx, `DELETE FROM two_factor_challenges WHERE expiry < $1`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return cmd.RowsAffected(), nil
+}
@@ -19,6 +19,18 @@ var (
 	ErrDuplicateUsername = errors.New("a user with that username already exists")
 )
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleLevels = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
 type User struct {
 	ID        int       `json:"id"`
 	UserName  string    `json:"username"`
@@ -26,6 +38,23 @@ type User struct {
 	Password  password  `json:"-"`
 	CreatedAt time.Time `json:"created_at"`
 	IsActive  bool      `json:"is_active"`
+	Role      string    `json:"role"`
+	Locale    string    `json:"locale"`
+
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
+
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	Website     string `json:"website"`
+	AvatarURL   string `json:"avatar_url"`
+	IsPrivate   bool   `json:"is_private"`
+
+	DeletionScheduledFor *time.Time `json:"deletion_scheduled_for,omitempty"`
+}
+
+// HasRole reports whether the user's role is at least as privileged as role.
+func (user *User) HasRole(role string) bool {
+	return roleLevels[user.Role] >= roleLevels[role]
 }
 
 type password struct {
@@ -45,6 +74,10 @@ func (password *password) Set(text string) error {
 	return nil
 }
 
+func (password *password) Compare(text string) error {
+	return bcrypt.CompareHashAndPassword(password.hash, []byte(text))
+}
+
 type UserStore struct {
 	db *pgxpool.Pool
 }
@@ -110,9 +143,9 @@ func (useStore *UserStore) getUserFromInvitation(
 }
 
 func (userStore *UserStore) Create(ctx context.Context, txn pgx.Tx, user *User) error {
-	query := `INSERT INTO users (username,password,email) 
-			  VALUES ($1,$2,$3)
-			  RETURNING id, created_at`
+	query := `INSERT INTO users (username,password,email,locale) 
+			  VALUES ($1,$2,$3,COALESCE(NULLIF($4,''),'en'))
+			  RETURNING id, created_at, role, locale`
 
 	err := txn.QueryRow(
 		ctx,
@@ -120,25 +153,33 @@ func (userStore *UserStore) Create(ctx context.Context, txn pgx.Tx, user *User)
 		user.UserName,
 		user.Password.hash,
 		user.Email,
-	).Scan(&user.ID, &user.CreatedAt)
+		user.Locale,
+	).Scan(&user.ID, &user.CreatedAt, &user.Role, &user.Locale)
 
-	if err != nil {
-		switch {
-		case strings.Contains(err.Error(), "duplicate key value violates unique constraint \"users_email_key\""):
-			return ErrDuplicateEmail
-		case strings.Contains(err.Error(), "duplicate key value violates unique constraint \"users_username_key\""):
-			return ErrDuplicateUsername
-		default:
-			return err
-		}
+	return duplicateUserError(err)
+}
+
+// duplicateUserError translates unique violations on users into
+// ErrDuplicateEmail and ErrDuplicateUsername.
+func duplicateUserError(err error) error {
+	if err == nil {
+		return nil
 	}
 
-	return nil
+	switch {
+	case strings.Contains(err.Error(), "duplicate key value violates unique constraint \"users_email_key\""):
+		return ErrDuplicateEmail
+	case strings.Contains(err.Error(), "duplicate key value violates unique constraint \"users_username_key\""):
+		return ErrDuplicateUsername
+	default:
+		return err
+	}
 }
 
 func (usersStore *UserStore) GetUserById(ctx context.Context, userId int) (*User, error) {
 
-	query := `SELECT id, email, username,  created_at
+	query := `SELECT id, email, username, created_at, is_active, role, locale, totp_enabled,
+			  deletion_scheduled_for, display_name, bio, website, avatar_url, is_private
 			  FROM users
 			  WHERE id=$1
 			`
@@ -153,6 +194,16 @@ func (usersStore *UserStore) GetUserById(ctx context.Context, userId int) (*User
 		&user.Email,
 		&user.UserName,
 		&user.CreatedAt,
+		&user.IsActive,
+		&user.Role,
+		&user.Locale,
+		&user.TwoFactorEnabled,
+		&user.DeletionScheduledFor,
+		&user.DisplayName,
+		&user.Bio,
+		&user.Website,
+		&user.AvatarURL,
+		&user.IsPrivate,
 	)
 
 	if err != nil {
@@ -162,6 +213,42 @@ func (usersStore *UserStore) GetUserById(ctx context.Context, userId int) (*User
 	return user, nil
 }
 
+func (usersStore *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+
+	query := `SELECT id, email, username, password, created_at, is_active, role, locale, totp_enabled
+			  FROM users
+			  WHERE email=$1
+			`
+	var user = &User{}
+
+	err := usersStore.db.QueryRow(
+		ctx,
+		query,
+		email,
+	).Scan(
+		&user.ID,
+		&user.Email,
+		&user.UserName,
+		&user.Password.hash,
+		&user.CreatedAt,
+		&user.IsActive,
+		&user.Role,
+		&user.Locale,
+		&user.TwoFactorEnabled,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
 func (usersStore *UserStore) CreateAndInvite(
 	ctx context.Context,
 	user *User,
@@ -181,6 +268,78 @@ func (usersStore *UserStore) CreateAndInvite(
 	})
 }
 
+func (userStore *UserStore) Delete(ctx context.Context, userId int) error {
+	return withTransaction(userStore.db, ctx, func(tx pgx.Tx) error {
+		if err := userStore.deleteUserInvitation(ctx, tx, userId); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, userId); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// RotateInvitation replaces any invitation of the user with a fresh token.
+func (userStore *UserStore) RotateInvitation(
+	ctx context.Context,
+	userId int,
+	token string,
+	invitationExp time.Duration,
+) error {
+	return withTransaction(userStore.db, ctx, func(tx pgx.Tx) error {
+		if err := userStore.deleteUserInvitation(ctx, tx, userId); err != nil {
+			return err
+		}
+
+		return userStore.createUserInvitation(ctx, tx, token, invitationExp, userId)
+	})
+}
+
+func (userStore *UserStore) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	query := `DELETE FROM user_invitations WHERE expiry < $1`
+
+	cmd, err := userStore.db.Exec(ctx, query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return cmd.RowsAffected(), nil
+}
+
+// PurgeInactive deletes accounts that were never activated within olderThan
+// of signing up. Users that already own posts or comments are kept.
+func (userStore *UserStore) PurgeInactive(ctx context.Context, olderThan time.Duration) (int64, error) {
+	condition := `
+		is_active = FALSE AND created_at < $1
+		AND NOT EXISTS (SELECT 1 FROM posts p WHERE p.user_id = users.id)
+		AND NOT EXISTS (SELECT 1 FROM comments c WHERE c.user_id = users.id)
+	`
+	cutoff := time.Now().Add(-olderThan)
+
+	var purged int64
+
+	err := withTransaction(userStore.db, ctx, func(tx pgx.Tx) error {
+		query := `DELETE FROM user_invitations WHERE user_id IN (SELECT id FROM users WHERE ` + condition + `)`
+		if _, err := tx.Exec(ctx, query, cutoff); err != nil {
+			return err
+		}
+
+		cmd, err := tx.Exec(ctx, `DELETE FROM users WHERE `+condition, cutoff)
+		if err != nil {
+			return err
+		}
+
+		purged = cmd.RowsAffected()
+
+		return nil
+	})
+
+	return purged, err
+}
+
 func (userStore *UserStore) createUserInvitation(
 	ctx context.Context,
 	tx pgx.Tx,
@@ -210,12 +369,93 @@ func (userStore *UserStore) update(context context.Context, transaction pgx.Tx,
 		user.IsActive,
 		user.ID,
 	); err != nil {
-		return err
+		return duplicateUserError(err)
 	}
 
 	return nil
 }
 
+// Update saves the account and profile fields of a user loaded with GetUserById.
+func (userStore *UserStore) Update(ctx context.Context, user *User) error {
+	return withTransaction(userStore.db, ctx, func(tx pgx.Tx) error {
+		if err := userStore.update(ctx, tx, user); err != nil {
+			return err
+		}
+
+		query := `UPDATE users
+				  SET display_name = $1, bio = $2, website = $3, avatar_url = $4, is_private = $5
+				  WHERE id = $6`
+
+		_, err := tx.Exec(
+			ctx,
+			query,
+			user.DisplayName,
+			user.Bio,
+			user.Website,
+			user.AvatarURL,
+			user.IsPrivate,
+			user.ID,
+		)
+
+		return err
+	})
+}
+
+// Profile is the public view of a user.
+type Profile struct {
+	ID             int       `json:"id"`
+	UserName       string    `json:"username"`
+	Email          string    `json:"email,omitempty"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	Website        string    `json:"website"`
+	AvatarURL      string    `json:"avatar_url"`
+	IsPrivate      bool      `json:"is_private"`
+	CreatedAt      time.Time `json:"created_at"`
+	FollowerCount  int       `json:"follower_count"`
+	FollowingCount int       `json:"following_count"`
+	PostCount      int       `json:"post_count"`
+}
+
+// GetProfile returns the profile of a user with its follower, following and
+// post counts. The email is included so callers can decide who may see it.
+func (userStore *UserStore) GetProfile(ctx context.Context, userId int) (*Profile, error) {
+	query := `SELECT u.id, u.username, u.email, u.display_name, u.bio, u.website, u.avatar_url, u.is_private,
+			  u.created_at,
+			  (SELECT COUNT(*) FROM followers f WHERE f.user_id = u.id),
+			  (SELECT COUNT(*) FROM followers f WHERE f.follower_id = u.id),
+			  (SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id)
+			  FROM users u
+			  WHERE u.id = $1`
+
+	var profile Profile
+	err := userStore.db.QueryRow(ctx, query, userId).Scan(
+		&profile.ID,
+		&profile.UserName,
+		&profile.Email,
+		&profile.DisplayName,
+		&profile.Bio,
+		&profile.Website,
+		&profile.AvatarURL,
+		&profile.IsPrivate,
+		&profile.CreatedAt,
+		&profile.FollowerCount,
+		&profile.FollowingCount,
+		&profile.PostCount,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &profile, nil
+}
+
 func (userStore *UserStore) deleteUserInvitation(
 	context context.Context,
 	transaction pgx.Tx,
@@ -229,3 +469,354 @@ func (userStore *UserStore) deleteUserInvitation(
 
 	return nil
 }
+
+func (userStore *UserStore) CreatePasswordReset(
+	ctx context.Context,
+	userId int,
+	token string,
+	exp time.Duration,
+) error {
+	return withTransaction(userStore.db, ctx, func(tx pgx.Tx) error {
+		if err := userStore.deletePasswordResets(ctx, tx, userId); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO password_resets (token, user_id, expiry) VALUES ($1,$2,$3)`
+
+		if _, err := tx.Exec(ctx, query, token, userId, time.Now().Add(exp)); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// GetByPasswordReset returns the user a valid, unused reset token belongs to,
+// so the new password can be checked against their identity first.
+func (userStore *UserStore) GetByPasswordReset(ctx context.Context, token string) (*User, error) {
+	var user *User
+
+	err := withTransaction(userStore.db, ctx, func(tx pgx.Tx) error {
+		var err error
+		user, err = userStore.getUserFromPasswordReset(ctx, tx, token)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (userStore *UserStore) ResetPassword(ctx context.Context, token string, newPassword string) (*User, error) {
+	var user *User
+
+	err := withTransaction(userStore.db, ctx, func(tx pgx.Tx) error {
+		var err error
+		user, err = userStore.getUserFromPasswordReset(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		if err := user.Password.Set(newPassword); err != nil {
+			return err
+		}
+
+		if err := userStore.updatePassword(ctx, tx, user); err != nil {
+			return err
+		}
+
+		return userStore.deletePasswordResets(ctx, tx, user.ID)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (userStore *UserStore) getUserFromPasswordReset(
+	ctx context.Context,
+	tx pgx.Tx,
+	token string,
+) (*User, error) {
+	query := `
+		SELECT u.id, u.username, u.email, u.is_active
+		FROM users u
+		INNER JOIN password_resets pr
+		ON u.id = pr.user_id
+		WHERE pr.token = $1 AND pr.expiry > $2
+	`
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	var user User
+
+	if err := tx.QueryRow(ctx, query, hashToken, time.Now()).Scan(
+		&user.ID,
+		&user.UserName,
+		&user.Email,
+		&user.IsActive,
+	); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+func (userStore *UserStore) updatePassword(ctx context.Context, tx pgx.Tx, user *User) error {
+	query := `UPDATE users SET password = $1 WHERE id = $2`
+
+	if _, err := tx.Exec(ctx, query, user.Password.hash, user.ID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (userStore *UserStore) deletePasswordResets(ctx context.Context, tx pgx.Tx, userId int) error {
+	query := `DELETE FROM password_resets WHERE user_id = $1`
+
+	if _, err := tx.Exec(ctx, query, userId); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateMagicLink stores a login link for the user, replacing any link that
+// has not been used yet.
+func (userStore *UserStore) CreateMagicLink(
+	ctx context.Context,
+	userId int,
+	token string,
+	exp time.Duration,
+) error {
+	return withTransaction(userStore.db, ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM magic_links WHERE user_id = $1`, userId); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO magic_links (token, user_id, expiry) VALUES ($1,$2,$3)`
+
+		if _, err := tx.Exec(ctx, query, token, userId, time.Now().Add(exp)); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// RedeemMagicLink consumes a live login link and returns its user. A link can
+// only be redeemed once.
+func (userStore *UserStore) RedeemMagicLink(ctx context.Context, token string) (*User, error) {
+	query := `DELETE FROM magic_links
+			  WHERE token = $1 AND expiry > $2
+			  RETURNING user_id`
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	var userId int
+	if err := userStore.db.QueryRow(ctx, query, hashToken, time.Now()).Scan(&userId); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrorNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return userStore.GetUserById(ctx, userId)
+}
+
+func (userStore *UserStore) DeleteExpiredMagicLinks(ctx context.Context) (int64, error) {
+	cmd, err := userStore.db.Exec(ctx, `DELETE FROM magic_links WHERE expiry < $1`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return cmd.RowsAffected(), nil
+}
+
+// CreateEmailChange stores a pending change to email, replacing any change the
+// user has not confirmed yet. The current address stays in use until then.
+func (userStore *UserStore) CreateEmailChange(
+	ctx context.Context,
+	userId int,
+	email string,
+	token string,
+	exp time.Duration,
+) error {
+	return withTransaction(userStore.db, ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM email_changes WHERE user_id = $1`, userId); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO email_changes (token, user_id, email, expiry) VALUES ($1,$2,$3,$4)`
+
+		if _, err := tx.Exec(ctx, query, token, userId, email, time.Now().Add(exp)); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// ConfirmEmailChange applies a pending email change. It fails with
+// ErrDuplicateEmail when the address was taken in the meantime.
+func (userStore *UserStore) ConfirmEmailChange(ctx context.Context, token string) (*User, error) {
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	var userId int
+
+	err := withTransaction(userStore.db, ctx, func(tx pgx.Tx) error {
+		query := `DELETE FROM email_changes
+				  WHERE token = $1 AND expiry > $2
+				  RETURNING user_id, email`
+
+		var email string
+		if err := tx.QueryRow(ctx, query, hashToken, time.Now()).Scan(&userId, &email); err != nil {
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+				return ErrorNotFound
+			default:
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE users SET email = $1 WHERE id = $2`, email, userId); err != nil {
+			return duplicateUserError(err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return userStore.GetUserById(ctx, userId)
+}
+
+func (userStore *UserStore) DeleteExpiredEmailChanges(ctx context.Context) (int64, error) {
+	cmd, err := userStore.db.Exec(ctx, `DELETE FROM email_changes WHERE expiry < $1`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return cmd.RowsAffected(), nil
+}
+
+// ScheduleDeletion marks the account to be deleted once grace has passed and
+// returns when that happens. An account already scheduled keeps its date.
+func (userStore *UserStore) ScheduleDeletion(ctx context.Context, userId int, grace time.Duration) (time.Time, error) {
+	query := `UPDATE users
+			  SET deletion_scheduled_for = COALESCE(deletion_scheduled_for, $1)
+			  WHERE id = $2
+			  RETURNING deletion_scheduled_for`
+
+	var scheduledFor time.Time
+	if err := userStore.db.QueryRow(ctx, query, time.Now().Add(grace), userId).Scan(&scheduledFor); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return time.Time{}, ErrorNotFound
+		default:
+			return time.Time{}, err
+		}
+	}
+
+	return scheduledFor, nil
+}
+
+// CancelDeletion clears a pending deletion. It reports whether one was pending.
+func (userStore *UserStore) CancelDeletion(ctx context.Context, userId int) (bool, error) {
+	query := `UPDATE users SET deletion_scheduled_for = NULL
+			  WHERE id = $1 AND deletion_scheduled_for IS NOT NULL`
+
+	cmd, err := userStore.db.Exec(ctx, query, userId)
+	if err != nil {
+		return false, err
+	}
+
+	return cmd.RowsAffected() > 0, nil
+}
+
+// DeleteScheduled deletes the accounts whose grace period has ended. Their
+// posts are removed together with the comments on them, while their comments
+// on other posts are kept without an author.
+func (userStore *UserStore) DeleteScheduled(ctx context.Context) (int64, error) {
+	query := `SELECT id FROM users
+			  WHERE deletion_scheduled_for IS NOT NULL AND deletion_scheduled_for < $1`
+
+	rows, err := userStore.db.Query(ctx, query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	userIds, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, userId := range userIds {
+		var due bool
+
+		if err := withTransaction(userStore.db, ctx, func(tx pgx.Tx) error {
+			// The user may have cancelled since the ids were selected, so the
+			// schedule is checked again with the row locked.
+			query := `SELECT deletion_scheduled_for IS NOT NULL AND deletion_scheduled_for < $2
+					  FROM users WHERE id = $1 FOR UPDATE`
+
+			err := tx.QueryRow(ctx, query, userId, time.Now()).Scan(&due)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			if err != nil || !due {
+				return err
+			}
+
+			return userStore.deleteAccount(ctx, tx, userId)
+		}); err != nil {
+			return deleted, err
+		}
+
+		if due {
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (userStore *UserStore) deleteAccount(ctx context.Context, tx pgx.Tx, userId int) error {
+	queries := []string{
+		`DELETE FROM comments WHERE post_id IN (SELECT id FROM posts WHERE user_id = $1)`,
+		`DELETE FROM posts WHERE user_id = $1`,
+		`UPDATE comments SET user_id = NULL WHERE user_id = $1`,
+		`DELETE FROM user_invitations WHERE user_id = $1`,
+		`DELETE FROM users WHERE id = $1`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(ctx, query, userId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Export returns the user's profile for a personal data export.
+func (userStore *UserStore) Export(ctx context.Context, userId int) (any, error) {
+	return userStore.GetUserById(ctx, userId)
+}